@@ -0,0 +1,26 @@
+package corral
+
+import (
+	"context"
+	"net/http"
+)
+
+// Authenticator returns Middleware as a value, for routers that take
+// func(http.Handler) http.Handler directly (chi's Use, gorilla/mux's
+// Use). Echo users can wrap it with echo.WrapMiddleware.
+func (v *Validator) Authenticator() func(next http.Handler) http.Handler {
+	return v.Middleware
+}
+
+// TokenAuthFunc returns a validator in the func(token) (bool, any) shape
+// used by key-auth style middlewares. The second result is the *User on
+// success and nil otherwise.
+func (v *Validator) TokenAuthFunc() func(token string) (bool, any) {
+	return func(token string) (bool, any) {
+		user, err := v.ValidateSessionContext(context.Background(), token)
+		if err != nil || user == nil {
+			return false, nil
+		}
+		return true, user
+	}
+}
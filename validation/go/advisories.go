@@ -0,0 +1,56 @@
+package corral
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// WithIndexAdvisories makes NewValidator check the database for missing
+// indexes the hot path relies on (currently the session token column) and
+// log a warning for each.
+func WithIndexAdvisories(enabled bool) Option {
+	return func(v *Validator) {
+		v.indexAdvisories = enabled
+	}
+}
+
+// adviseIndexes logs a warning if the session token column is not the
+// leading column of any index.
+func (v *Validator) adviseIndexes(ctx context.Context) {
+	db, err := v.querier()
+	if err != nil {
+		log.Printf("[corral] Index check skipped: %v", err)
+		return
+	}
+	indexed, err := leadingIndexed(ctx, db, unquoteIdent(v.sq.SessionTable), unquoteIdent(v.sq.TokenCol))
+	if err != nil {
+		log.Printf("[corral] Index check skipped: %v", err)
+		return
+	}
+	if !indexed {
+		log.Printf("[corral] Warning: %s.%s has no index; session lookups will scan the table. Consider: CREATE UNIQUE INDEX \"session_token_idx\" ON %s (%s)",
+			v.sq.SessionTable, v.sq.TokenCol, v.sq.SessionTable, v.sq.TokenCol)
+	}
+}
+
+// leadingIndexed reports whether column is the first column of an index on
+// table. A missing table counts as indexed, since there is nothing to
+// advise on yet.
+func leadingIndexed(ctx context.Context, db Querier, table, column string) (bool, error) {
+	var cols int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?)`, table).Scan(&cols)
+	if err != nil || cols == 0 {
+		return true, err
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_index_list(?) l
+		 JOIN pragma_index_info(l.name) i
+		 WHERE i.seqno = 0 AND i.name = ?`, table, column).Scan(&n)
+	return n > 0, err
+}
+
+// unquoteIdent reverses quoteIdent.
+func unquoteIdent(quoted string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(quoted, `"`), `"`), `""`, `"`)
+}
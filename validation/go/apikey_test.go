@@ -0,0 +1,38 @@
+package corral
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyCustomSchema(t *testing.T) {
+	v, db := newTestValidator(t,
+		WithAPIKeys(true),
+		WithSchema(Schema{APIKeyTable: "api_key", APIKeyUserIDCol: "ownerId"}))
+	_, err := db.Exec(`CREATE TABLE api_key (id TEXT PRIMARY KEY, "key" TEXT, ownerId TEXT, enabled INTEGER,
+		expiresAt TEXT, permissions TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertUser(t, db, "u1", "pro", "user")
+	sum := sha256.Sum256([]byte("secret-key"))
+	_, err = db.Exec(`INSERT INTO api_key VALUES ('k1', ?, 'u1', 1, NULL, '{"files":["read"]}')`,
+		base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, scopes, err := v.authenticateAPIKey(context.Background(), "secret-key", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != "u1" || !scopes["files:read"] {
+		t.Errorf("got user %q scopes %v, want u1 with files:read", u.ID, scopes)
+	}
+	if _, _, err := v.authenticateAPIKey(context.Background(), "other-key", time.Now()); err != ErrSessionNotFound {
+		t.Errorf("unknown key: err = %v, want ErrSessionNotFound", err)
+	}
+}
@@ -0,0 +1,86 @@
+package corral
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// authLogSummaryInterval is how often suppressed auth server output is
+// summarized under WithAuthServerLogRateLimit.
+const authLogSummaryInterval = 10 * time.Second
+
+// WithAuthServerLogRateLimit logs at most linesPerSec lines of auth server
+// output per second, across stdout, stderr and all replicas, so a
+// log-spamming server can't flood the application's logs. Excess lines are
+// dropped and counted in Stats, with a "N lines suppressed" summary every
+// 10s. Dropped stderr lines still reach AuthServerExitError.Stderr.
+func WithAuthServerLogRateLimit(linesPerSec int) Option {
+	return func(v *Validator) {
+		v.authOutput.limit = linesPerSec
+	}
+}
+
+// authLog accounts for the auth server's output lines and applies the
+// WithAuthServerLogRateLimit limit.
+type authLog struct {
+	limit int // lines per second; 0 for no limit
+
+	lines      atomic.Uint64
+	suppressed atomic.Uint64
+
+	mu       sync.Mutex
+	window   time.Time
+	inWindow int
+	pending  uint64 // suppressed since the last summary
+}
+
+// allow counts a line and reports whether it may be logged.
+func (a *authLog) allow(now time.Time) bool {
+	a.lines.Add(1)
+	if a.limit <= 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.window) >= time.Second {
+		a.window, a.inWindow = now, 0
+	}
+	if a.inWindow < a.limit {
+		a.inWindow++
+		return true
+	}
+	a.suppressed.Add(1)
+	a.pending++
+	return false
+}
+
+// summarize logs how many lines were suppressed since the last summary.
+func (a *authLog) summarize() {
+	a.mu.Lock()
+	n := a.pending
+	a.pending = 0
+	a.mu.Unlock()
+	if n > 0 {
+		log.Printf("[corral-auth] %d lines suppressed (log rate limit)", n)
+	}
+}
+
+// startAuthLogSummaries summarizes suppressed output periodically and once
+// more at shutdown.
+func (v *Validator) startAuthLogSummaries() {
+	v.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(authLogSummaryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				v.authOutput.summarize()
+				return
+			case <-ticker.C:
+				v.authOutput.summarize()
+			}
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package corral
+
+import (
+	"context"
+	"time"
+)
+
+// AuthzRequest lists the requirements Authorize checks. Zero fields are
+// not checked.
+type AuthzRequest struct {
+	MinPlan              string        // see RequirePlan
+	RequiredRole         string        // see RequireRole
+	RequireVerifiedEmail bool          // User.EmailVerified must be set
+	RequireFresh         time.Duration // session created at most this long ago
+}
+
+// Authorize validates token and checks req against the result, for gRPC
+// handlers and jobs that can't use the middlewares. Unlike
+// ValidateSession, rejections are returned as errors: ErrNoToken,
+// ErrSessionNotFound, ErrSessionExpired or ErrUserBanned for the token,
+// else the first failed requirement in field order (ErrInsufficientPlan,
+// ErrInsufficientRole, ErrEmailNotVerified, ErrSessionNotFresh). Other
+// errors mean the session could not be checked.
+func (v *Validator) Authorize(ctx context.Context, token string, req AuthzRequest) (*User, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	ctx, end := v.startTrace(ctx)
+	u, s, err := v.authenticate(ctx, token)
+	traceErr := err
+	if isRejection(err) {
+		traceErr = nil
+	}
+	end(authResult{user: u, session: s, token: token, source: SourceArgument}, traceErr)
+	if err != nil {
+		return nil, err
+	}
+	v.warnExpiry(u, s)
+
+	switch {
+	case req.MinPlan != "" && !v.RequirePlan(u, req.MinPlan):
+		return nil, ErrInsufficientPlan
+	case req.RequiredRole != "" && !v.RequireRole(u, req.RequiredRole):
+		return nil, ErrInsufficientRole
+	case req.RequireVerifiedEmail && !u.EmailVerified:
+		return nil, ErrEmailNotVerified
+	case req.RequireFresh > 0 && v.now().Sub(s.CreatedAt) > req.RequireFresh:
+		return nil, ErrSessionNotFresh
+	}
+	return u, nil
+}
@@ -0,0 +1,90 @@
+package corral
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+const authProbeInterval = 2 * time.Second
+
+// AuthServerHealthy reports whether any managed auth server passed its most
+// recent health check. It is false until a server has started, and again
+// once all of them have exited.
+func (v *Validator) AuthServerHealthy() bool {
+	for _, p := range v.authServers() {
+		if p.healthy.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthProxyHandler returns a reverse proxy to the managed auth server, for
+// mounting at /api/auth/. Requests are spread round-robin across healthy
+// replicas. While none is healthy (still spawning, crashed, or never
+// started) it answers 503 with Retry-After: 1 instead of surfacing
+// connection errors. Session validation does not depend on it.
+func (v *Validator) AuthProxyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !v.limitAuthRequest(w, r) {
+			return
+		}
+		p := v.nextAuthServer()
+		if p == nil {
+			authUnavailable(w)
+			return
+		}
+		target, _ := url.Parse(p.base)
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			authUnavailable(w)
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// nextAuthServer picks the next healthy replica round-robin, or nil.
+func (v *Validator) nextAuthServer() *authProc {
+	procs := v.authServers()
+	n := len(procs)
+	start := int(v.authNext.Add(1) % uint64(max(n, 1)))
+	for i := 0; i < n; i++ {
+		if p := procs[(start+i)%n]; p.healthy.Load() {
+			return p
+		}
+	}
+	return nil
+}
+
+func authUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "auth server unavailable", http.StatusServiceUnavailable)
+}
+
+func probeAuthServer(client *http.Client, healthURL string) bool {
+	resp, err := client.Get(healthURL)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// probeAuthServerLoop keeps p's health current after startup until the
+// validator shuts down or the process exits.
+func probeAuthServerLoop(stop <-chan struct{}, p *authProc, client *http.Client) {
+	ticker := time.NewTicker(authProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-p.exited:
+			return
+		case <-ticker.C:
+			p.healthy.Store(probeAuthServer(client, p.healthURL()))
+		}
+	}
+}
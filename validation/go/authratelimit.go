@@ -0,0 +1,87 @@
+package corral
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WithAuthEndpointRateLimit makes AuthProxyHandler allow each client IP at
+// most max state-changing requests (sign-in, sign-up, password reset; any
+// method but GET, HEAD and OPTIONS) per window, answering 429 with
+// Retry-After before the request reaches the auth server. Rate-limit
+// headers set by the auth server itself are passed through unchanged.
+func WithAuthEndpointRateLimit(max int, window time.Duration) Option {
+	return func(v *Validator) {
+		v.authLimiter = &ipLimiters{
+			limit:    rate.Limit(float64(max) / window.Seconds()),
+			burst:    max,
+			idleTTL:  window,
+			limiters: make(map[string]*ipLimiter),
+		}
+	}
+}
+
+// ipLimiters holds a token bucket per client IP, dropping idle ones.
+type ipLimiters struct {
+	limit   rate.Limit
+	burst   int
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*ipLimiter
+	lastSweep time.Time
+}
+
+type ipLimiter struct {
+	lim      *rate.Limiter
+	lastSeen time.Time
+}
+
+// reserve takes a token for ip, returning how long to wait if none is
+// available.
+func (p *ipLimiters) reserve(ip string, now time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.lastSweep) >= p.idleTTL {
+		for k, l := range p.limiters {
+			if now.Sub(l.lastSeen) >= p.idleTTL {
+				delete(p.limiters, k)
+			}
+		}
+		p.lastSweep = now
+	}
+	l, ok := p.limiters[ip]
+	if !ok {
+		l = &ipLimiter{lim: rate.NewLimiter(p.limit, p.burst)}
+		p.limiters[ip] = l
+	}
+	l.lastSeen = now
+	r := l.lim.ReserveN(now, 1)
+	if !r.OK() {
+		return time.Second, false
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// limitAuthRequest answers 429 and reports false if r exceeds the auth
+// endpoint rate limit.
+func (v *Validator) limitAuthRequest(w http.ResponseWriter, r *http.Request) bool {
+	if v.authLimiter == nil || safeMethod(r.Method) {
+		return true
+	}
+	if delay, ok := v.authLimiter.reserve(v.clientIP(r), v.now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
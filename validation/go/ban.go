@@ -0,0 +1,79 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ErrUserBanned is returned by ValidateSession and friends when the
+// session is valid but its user is banned (Better Auth admin plugin). The
+// middlewares answer 403.
+var ErrUserBanned = errors.New("corral: user banned")
+
+// banState is a user row's banned and banExpires columns (Better Auth
+// admin plugin), read alongside the user by getUserWithBan and
+// usersByIDs.
+type banState struct {
+	banned  sql.NullBool
+	expires sql.NullString
+}
+
+// activeAt reports whether the ban on userID is in force at now. An expiry
+// that cannot be parsed is logged and treated as permanent, so a bad row
+// keeps the user out without failing validation.
+func (b banState) activeAt(userID string, now time.Time) bool {
+	if !b.banned.Bool {
+		return false
+	}
+	if !b.expires.Valid || b.expires.String == "" {
+		return true
+	}
+	until, err := parseBanExpiry(b.expires.String)
+	if err != nil {
+		log.Printf("[corral] User %s has unparseable banExpires %q; treating ban as permanent", userID, b.expires.String)
+		return true
+	}
+	return until.After(now)
+}
+
+// getUserWithBan is getUserByID that also reads the ban columns in the same
+// query. Databases without them are detected on first use and read with
+// selectUser from then on, reporting no ban; any other missing column is
+// returned as an error and leaves ban checks on.
+func (v *Validator) getUserWithBan(ctx context.Context, db Querier, userID string) (*User, banState, error) {
+	var ban banState
+	if v.banColsMissing.Load() {
+		u, err := v.getUserByID(ctx, db, userID)
+		return u, ban, err
+	}
+	done := v.timeQuery(QueryUser)
+	u, err := scanUser(db.QueryRowContext(ctx, v.queries.selectUserBan, userID), &ban.banned, &ban.expires)
+	done()
+	if err != nil && (isMissingTableColumn(err, "banned") || isMissingTableColumn(err, "banExpires")) {
+		v.disableBanChecks()
+		u, err := v.getUserByID(ctx, db, userID)
+		return u, banState{}, err
+	}
+	u, err = v.finishUser(ctx, db, u, err)
+	return u, ban, err
+}
+
+// disableBanChecks records that the user table has no ban columns.
+func (v *Validator) disableBanChecks() {
+	if v.banColsMissing.CompareAndSwap(false, true) {
+		log.Printf("[corral] No banned/banExpires columns on user table; ban checks disabled")
+	}
+}
+
+// parseBanExpiry accepts the stored timestamp formats plus Unix
+// milliseconds, which some adapters write for date columns.
+func parseBanExpiry(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return parseTime(value)
+}
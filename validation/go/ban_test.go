@@ -0,0 +1,72 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// breakOnce rewrites the first query selecting the ban columns to read a
+// missing, unrelated user column instead.
+type breakOnce struct {
+	*sql.DB
+	done atomic.Bool
+}
+
+func (q *breakOnce) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if strings.Contains(query, `"banned"`) && q.done.CompareAndSwap(false, true) {
+		query = strings.Replace(query, `"user"."banned"`, `"user"."nickname"`, 1)
+	}
+	return q.DB.QueryRowContext(ctx, query, args...)
+}
+
+func TestBanChecksSurviveUnrelatedMissingColumn(t *testing.T) {
+	_, db := newTestValidator(t)
+	for _, stmt := range []string{
+		`ALTER TABLE user ADD COLUMN banned INTEGER`,
+		`ALTER TABLE user ADD COLUMN banExpires TEXT`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertUser(t, db, "u1", "free", "user")
+	if _, err := db.Exec(`UPDATE user SET banned = 1 WHERE id = 'u1'`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	insertSession(t, db, "tok1", "u1", now.Add(-time.Hour), now.Add(time.Hour))
+
+	v, err := newValidator("", []Option{WithQuerier(&breakOnce{DB: db})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, _, err := v.authenticate(ctx, "tok1"); err == nil || !isMissingColumn(err) {
+		t.Fatalf("first validation: err = %v, want the missing column error", err)
+	}
+	if v.banColsMissing.Load() {
+		t.Fatal("ban checks disabled by an unrelated missing column")
+	}
+	if _, _, err := v.authenticate(ctx, "tok1"); !errors.Is(err, ErrUserBanned) {
+		t.Fatalf("second validation: err = %v, want ErrUserBanned", err)
+	}
+}
+
+func TestBanChecksDisabledWithoutBanColumns(t *testing.T) {
+	v, db := newTestValidator(t)
+	insertUser(t, db, "u1", "free", "user")
+	now := time.Now()
+	insertSession(t, db, "tok1", "u1", now.Add(-time.Hour), now.Add(time.Hour))
+
+	if _, _, err := v.authenticate(context.Background(), "tok1"); err != nil {
+		t.Fatal(err)
+	}
+	if !v.banColsMissing.Load() {
+		t.Error("ban checks still on without banned/banExpires columns")
+	}
+}
@@ -0,0 +1,181 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of querying the database while the
+// WithCircuitBreaker breaker is open.
+var ErrCircuitOpen = errors.New("corral: circuit breaker open")
+
+// BreakerMode selects how Middleware answers while the breaker is open.
+type BreakerMode int
+
+const (
+	// BreakerFailClosed answers 503 with Retry-After.
+	BreakerFailClosed BreakerMode = iota
+	// BreakerFailOpen passes requests to the next handler without a User,
+	// as if they were skipped. Only use it when every handler behind
+	// Middleware checks UserFromContext itself.
+	BreakerFailOpen
+)
+
+// BreakerState is the state of the WithCircuitBreaker breaker.
+type BreakerState int
+
+const (
+	BreakerDisabled BreakerState = iota
+	BreakerClosed                // validating normally
+	BreakerOpen                  // fast-failing until the cool-down ends
+	BreakerHalfOpen              // letting one validation through as a probe
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "disabled"
+}
+
+// WithCircuitBreaker stops querying the database after threshold
+// consecutive validation errors within window, so requests fail fast
+// instead of each waiting out a broken database. Rejected tokens don't
+// count as errors. While open, validation returns ErrCircuitOpen and
+// Middleware responds according to mode. After coolDown one validation is
+// let through; success closes the breaker and failure re-opens it. Cached
+// sessions keep validating throughout.
+func WithCircuitBreaker(threshold int, window, coolDown time.Duration, mode BreakerMode) Option {
+	return func(v *Validator) {
+		v.breaker = &breaker{
+			threshold: max(threshold, 1),
+			window:    window,
+			coolDown:  coolDown,
+			mode:      mode,
+			state:     BreakerClosed,
+		}
+	}
+}
+
+type breaker struct {
+	threshold int
+	window    time.Duration
+	coolDown  time.Duration
+	mode      BreakerMode
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	firstFail time.Time
+	openedAt  time.Time
+	probing   bool
+}
+
+// allow reports whether a validation may query the database now.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.coolDown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed validation.
+// Validations abandoned by the caller say nothing about the database and
+// only free the half-open probe slot.
+func (b *breaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		b.probing = false
+		return
+	}
+	if err == nil || isRejection(err) || errors.Is(err, ErrUserBanned) {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+	if b.state == BreakerHalfOpen {
+		b.trip(now)
+		return
+	}
+	if b.failures == 0 || now.Sub(b.firstFail) > b.window {
+		b.failures = 0
+		b.firstFail = now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip(now)
+	}
+}
+
+func (b *breaker) trip(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) snapshot() (BreakerState, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
+
+// retryAfter is how long until the breaker next lets a probe through.
+func (b *breaker) retryAfter(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.openedAt.Add(b.coolDown).Sub(now), time.Second)
+}
+
+// Stats is a snapshot of the validator's runtime state.
+type Stats struct {
+	Breaker         BreakerState // BreakerDisabled without WithCircuitBreaker
+	BreakerFailures int          // consecutive errors counted towards tripping
+
+	AuthLogLines      uint64 // non-empty output lines from the auth server
+	AuthLogSuppressed uint64 // lines dropped by WithAuthServerLogRateLimit
+}
+
+// Stats returns a snapshot of the validator's runtime state.
+func (v *Validator) Stats() Stats {
+	var s Stats
+	if v.breaker != nil {
+		s.Breaker, s.BreakerFailures = v.breaker.snapshot()
+	}
+	s.AuthLogLines = v.authOutput.lines.Load()
+	s.AuthLogSuppressed = v.authOutput.suppressed.Load()
+	return s
+}
+
+// circuitOpen answers a request that could not be validated because the
+// breaker is open.
+func (v *Validator) circuitOpen(w http.ResponseWriter) {
+	secs := int(v.breaker.retryAfter(v.now()).Round(time.Second).Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	v.writeError(w, http.StatusServiceUnavailable, "service unavailable", ErrCircuitOpen)
+}
@@ -0,0 +1,158 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ValidateSessions validates many tokens at once, for batch endpoints
+// acting on behalf of several users. Sessions and users are each fetched
+// with a few IN (...) queries rather than one pair per token. The result
+// has an entry for every token: the User, or nil if the token is missing,
+// expired or its user banned. Checks and sliding refresh match
+// ValidateSession; an error means the database could not be read.
+func (v *Validator) ValidateSessions(ctx context.Context, tokens []string) (map[string]*User, error) {
+	if v.configErr != nil {
+		return nil, v.configErr
+	}
+	result := make(map[string]*User, len(tokens))
+	now := v.now()
+	var pending []string
+	for _, token := range tokens {
+		if _, seen := result[token]; seen {
+			continue
+		}
+		result[token] = nil
+		if (v.maxTokenLength > 0 && len(token) > v.maxTokenLength) ||
+			(v.tokenPattern != nil && !v.tokenPattern.MatchString(token)) {
+			continue
+		}
+		if u, s, ok := v.cachedSession(v.sessionKey(token), now); ok && !v.tooOld(s, now) && v.userCheck != CheckUserAlways {
+			result[token] = u
+			continue
+		}
+		pending = append(pending, token)
+	}
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	db, err := v.querier()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := v.lookupSessions(ctx, db, pending)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, s := range sessions {
+		if !seen[s.UserID] {
+			seen[s.UserID] = true
+			userIDs = append(userIDs, s.UserID)
+		}
+	}
+	userDB, err := v.usersQueryer(db)
+	if err != nil {
+		return nil, err
+	}
+	checks := make(map[string]userChecks)
+	users, err := v.usersByIDs(ctx, userDB, userIDs, checks)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	for _, token := range pending {
+		s := sessions[v.dbToken(token)]
+		if s == nil && len(v.queries.selectSession) > 1 {
+			// Matched by another WithSessionLookupColumns column, if at
+			// all; those are rare enough to validate one by one.
+			u, _, err := v.authenticate(ctx, token)
+			if err != nil && !isRejection(err) && !errors.Is(err, ErrUserBanned) {
+				return nil, err
+			}
+			result[token] = u
+			continue
+		}
+		if s == nil || s.ExpiresAt.Before(now) || v.tooOld(s, now) {
+			continue
+		}
+		u := users[s.UserID]
+		if u == nil {
+			continue
+		}
+		stale, err := createdBefore(s, checks[u.ID].changedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		if stale {
+			continue
+		}
+		if checks[u.ID].ban.activeAt(u.ID, now) {
+			continue
+		}
+		v.maybeRefresh(ctx, db, s, now)
+		if v.cache != nil {
+			v.cacheSet(v.sessionKey(token), u, s, now)
+		}
+		copied := *u
+		result[token] = &copied
+	}
+	return result, nil
+}
+
+// lookupSessions fetches the sessions for tokens by the token column in
+// batches, keyed by the stored token (see WithTokenTransform). Rows with
+// a NULL userId or expiresAt are skipped, as in lookupSession.
+func (v *Validator) lookupSessions(ctx context.Context, db Querier, tokens []string) (map[string]*Session, error) {
+	sq := v.sq
+	sessions := make(map[string]*Session, len(tokens))
+	for start := 0; start < len(tokens); start += maxQueryVars {
+		chunk := tokens[start:min(start+maxQueryVars, len(tokens))]
+		args := make([]any, len(chunk))
+		for i, token := range chunk {
+			args[i] = v.dbToken(token)
+		}
+		query := fmt.Sprintf(`SELECT %s, %s, %s, %s, %s FROM %s WHERE %s IN (%s)`,
+			sq.SessionIDCol, sq.TokenCol, sq.UserIDCol, sq.ExpiresCol, sq.SessionCreatedCol,
+			sq.SessionTable, sq.TokenCol, placeholders(len(chunk)))
+		done := v.timeQuery(QuerySession)
+		rows, err := db.QueryContext(ctx, query, args...)
+		done()
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			s := &Session{}
+			var userID, expiresAt, createdAt sql.NullString
+			if err := rows.Scan(&s.ID, &s.Token, &userID, &expiresAt, &createdAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !userID.Valid || !expiresAt.Valid {
+				continue
+			}
+			s.UserID = userID.String
+			if s.ExpiresAt, err = parseTime(expiresAt.String); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if createdAt.Valid {
+				if s.CreatedAt, err = parseTime(createdAt.String); err != nil {
+					rows.Close()
+					return nil, err
+				}
+			}
+			s.LongLived = s.ExpiresAt.Sub(s.CreatedAt) > v.longLivedAfter
+			sessions[s.Token] = s
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,340 @@
+package corral
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InvalidationBus broadcasts session invalidations between validator
+// instances that each keep a local session cache, e.g. over Redis pub/sub.
+// Keys are opaque hashes of session tokens, never the tokens themselves.
+type InvalidationBus interface {
+	// Publish announces that key should be purged from every cache.
+	Publish(ctx context.Context, key string) error
+	// Subscribe calls handle for each published key until ctx is done.
+	Subscribe(ctx context.Context, handle func(key string)) error
+}
+
+// Cache stores validated sessions for WithSessionCacheBackend, keyed by an
+// opaque hash of the session token. The validator copies values on the way
+// in and out and checks expires itself, so implementations may return
+// expired entries and need no clock. Implementations must be safe for
+// concurrent use; Get reports ok=false for missing keys.
+//
+// Entries hold the Session alongside the User because a cache hit has to
+// answer everything a database hit does: the absolute age limit and
+// sliding expiration read CreatedAt and ExpiresAt, and Middleware exposes
+// the session through SessionFromContext.
+type Cache interface {
+	Get(key string) (user *User, session *Session, expires time.Time, ok bool)
+	Set(key string, user *User, session *Session, expires time.Time)
+	Delete(key string)
+}
+
+// WithSessionCache caches validated sessions in memory for up to ttl (never
+// past the session's own expiry), holding at most max entries. Revoked
+// sessions stay valid on other instances until ttl unless an
+// InvalidationBus is configured.
+func WithSessionCache(ttl time.Duration, max int) Option {
+	return WithSessionCacheBackend(NewMemoryCache(max), ttl)
+}
+
+// WithSessionCacheBackend is like WithSessionCache but stores sessions in
+// c, e.g. a Redis-backed Cache shared by every instance so that entries
+// survive restarts. Pair it with an InvalidationBus, or have c drop keys on
+// Delete fleet-wide, so revocations reach every instance.
+func WithSessionCacheBackend(c Cache, ttl time.Duration) Option {
+	return func(v *Validator) {
+		v.cache = c
+		v.cacheTTL = ttl
+	}
+}
+
+// WithInvalidationBus shares RevokeSession across instances: revocations are
+// published on bus, and keys received from it are purged from the local
+// session cache.
+func WithInvalidationBus(bus InvalidationBus) Option {
+	return func(v *Validator) {
+		v.bus = bus
+	}
+}
+
+// RevokeSession deletes the session for token and purges it from the cache,
+// publishing the invalidation when an InvalidationBus is configured.
+func (v *Validator) RevokeSession(ctx context.Context, token string) error {
+	db, err := v.querier()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, v.sq.SessionTable, v.sq.TokenCol)
+	if _, err := db.ExecContext(ctx, query, v.dbToken(token)); err != nil {
+		return err
+	}
+	v.invalidate(ctx, token)
+	return nil
+}
+
+// invalidate drops token from the local cache and tells other instances.
+func (v *Validator) invalidate(ctx context.Context, token string) {
+	v.invalidateKey(ctx, v.sessionKey(token))
+}
+
+// invalidateKey is invalidate for a key from sessionKey or, for tokens read
+// back from the session table, cacheKey.
+func (v *Validator) invalidateKey(ctx context.Context, key string) {
+	v.dropCached(key, v.now())
+	if v.bus != nil {
+		if err := v.bus.Publish(ctx, key); err != nil {
+			log.Printf("[corral] Failed to publish session invalidation: %v", err)
+		}
+	}
+}
+
+// subscribeInvalidations purges keys received from the bus until shutdown.
+func (v *Validator) subscribeInvalidations() {
+	v.goBackground(func(stop <-chan struct{}) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-stop
+			cancel()
+		}()
+		err := v.bus.Subscribe(ctx, func(key string) {
+			v.dropCached(key, v.now())
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[corral] Invalidation bus subscription ended: %v", err)
+		}
+	})
+}
+
+// cachedSession looks key up in the session cache, then the snapshot.
+func (v *Validator) cachedSession(key string, now time.Time) (*User, *Session, bool) {
+	if v.cache != nil {
+		if u, s, ok := v.cacheGet(key, now); ok {
+			return u, s, true
+		}
+	}
+	if v.snap != nil {
+		return v.snapshotHit(key, now)
+	}
+	return nil, nil, false
+}
+
+// cacheGet returns copies of the user and session cached under key, if
+// the entry hasn't expired as of now.
+func (v *Validator) cacheGet(key string, now time.Time) (*User, *Session, bool) {
+	cu, cs, expires, ok := v.cache.Get(key)
+	if !ok || cu == nil || cs == nil {
+		return nil, nil, false
+	}
+	if !now.Before(expires) {
+		v.cache.Delete(key)
+		return nil, nil, false
+	}
+	u, s := *cu, *cs
+	u.Extra = maps.Clone(u.Extra)
+	u.Roles = slices.Clone(u.Roles)
+	s.Refreshed = false
+	return &u, &s, true
+}
+
+// cacheSet caches copies of u and s for the cache TTL, never past the
+// session's own expiry.
+func (v *Validator) cacheSet(key string, u *User, s *Session, now time.Time) {
+	expires := now.Add(v.cacheTTL)
+	if s.ExpiresAt.Before(expires) {
+		expires = s.ExpiresAt
+	}
+	cu, cs := *u, *s
+	cu.Extra = maps.Clone(u.Extra)
+	cu.Roles = slices.Clone(u.Roles)
+	v.cache.Set(key, &cu, &cs, expires)
+}
+
+// dropCached removes key from the session cache and snapshot.
+func (v *Validator) dropCached(key string, now time.Time) {
+	if v.cache != nil {
+		v.cache.Delete(key)
+	}
+	if v.snap != nil {
+		v.snap.drop(key, now)
+	}
+}
+
+// sessionKey is the cache key for a token as the client sends it. Keys hash
+// the stored form (see WithTokenTransform) so that sessions read back from
+// the session table, as by the snapshot and the bulk revocations, map to the
+// same entries.
+func (v *Validator) sessionKey(token string) string {
+	return cacheKey(v.dbToken(token))
+}
+
+// cacheKey hashes token so raw tokens are neither kept in memory nor sent
+// over the invalidation bus.
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// memoryCache is the in-memory Cache behind WithSessionCache. Entries are
+// also kept in a min-heap by expiry so that eviction is O(log n).
+type memoryCache struct {
+	max int
+
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry
+	byExpiry expiryHeap
+}
+
+type cacheEntry struct {
+	key     string
+	user    *User
+	session *Session
+	expires time.Time
+	index   int // position in byExpiry
+}
+
+// NewMemoryCache returns the in-memory Cache used by WithSessionCache,
+// holding at most max entries (0 for no limit). When full, the entry
+// closest to expiry is evicted.
+func NewMemoryCache(max int) Cache {
+	return &memoryCache{max: max, entries: make(map[string]*cacheEntry)}
+}
+
+func (c *memoryCache) Get(key string) (*User, *Session, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, nil, time.Time{}, false
+	}
+	return e.user, e.session, e.expires, true
+}
+
+func (c *memoryCache) Set(key string, u *User, s *Session, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.user, e.session, e.expires = u, s, expires
+		heap.Fix(&c.byExpiry, e.index)
+		return
+	}
+	if c.max > 0 && len(c.entries) >= c.max {
+		// Drop the entry that expires soonest (expired entries first).
+		victim := heap.Pop(&c.byExpiry).(*cacheEntry)
+		delete(c.entries, victim.key)
+	}
+	e := &cacheEntry{key: key, user: u, session: s, expires: expires}
+	heap.Push(&c.byExpiry, e)
+	c.entries[key] = e
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		heap.Remove(&c.byExpiry, e.index)
+		delete(c.entries, key)
+	}
+}
+
+// expiryHeap orders cache entries by expiry, soonest first.
+type expiryHeap []*cacheEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*cacheEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// WithNegativeCache remembers tokens that failed validation for ttl (keep it
+// to a few seconds), so repeated attempts with the same bogus token skip the
+// DB. At most max tokens are remembered; only hashes are stored. A session
+// created for a previously-failed token is honored once ttl has passed.
+func WithNegativeCache(ttl time.Duration, max int) Option {
+	return func(v *Validator) {
+		v.negCache = &negativeCache{ttl: ttl, max: max, entries: make(map[string]*cacheEntry)}
+	}
+}
+
+// negativeCache tracks recently invalid token hashes, separately from the
+// session cache so invalid tokens can never evict valid sessions. Entries
+// share one ttl, so the expiry heap also orders them by age.
+type negativeCache struct {
+	ttl time.Duration
+	max int
+
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry
+	byExpiry expiryHeap
+}
+
+func (c *negativeCache) has(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && !now.Before(e.expires) {
+		c.remove(e)
+		return false
+	}
+	return ok
+}
+
+// add remembers key until ttl from now. When full, the oldest entry (an
+// expired one, if any) makes room, in O(log n) however many tokens are
+// being sprayed.
+func (c *negativeCache) add(key string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.expires = now.Add(c.ttl)
+		heap.Fix(&c.byExpiry, e.index)
+		return
+	}
+	if c.max > 0 && len(c.entries) >= c.max {
+		c.remove(c.byExpiry[0])
+	}
+	e := &cacheEntry{key: key, expires: now.Add(c.ttl)}
+	heap.Push(&c.byExpiry, e)
+	c.entries[key] = e
+}
+
+func (c *negativeCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+}
+
+// remove drops e. Caller holds mu.
+func (c *negativeCache) remove(e *cacheEntry) {
+	heap.Remove(&c.byExpiry, e.index)
+	delete(c.entries, e.key)
+}
@@ -0,0 +1,88 @@
+package corral
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsSoonestExpiry(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := NewMemoryCache(3)
+	u, s := &User{}, &Session{}
+	c.Set("a", u, s, base.Add(3*time.Minute))
+	c.Set("b", u, s, base.Add(1*time.Minute))
+	c.Set("c", u, s, base.Add(2*time.Minute))
+	// Refreshing b pushes its expiry past the others.
+	c.Set("b", u, s, base.Add(5*time.Minute))
+
+	c.Set("d", u, s, base.Add(4*time.Minute)) // evicts c
+	c.Delete("a")
+	c.Set("e", u, s, base.Add(6*time.Minute)) // room left by a
+	c.Set("f", u, s, base.Add(7*time.Minute)) // evicts d
+
+	for key, want := range map[string]bool{"a": false, "b": true, "c": false, "d": false, "e": true, "f": true} {
+		if _, _, _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) ok = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func BenchmarkMemoryCacheSetFull(b *testing.B) {
+	const size = 10000
+	base := time.Now()
+	c := NewMemoryCache(size)
+	u, s := &User{}, &Session{}
+	keys := make([]string, 2*size)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	for i := 0; i < size; i++ {
+		c.Set(keys[i], u, s, base.Add(time.Duration(i)*time.Millisecond))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(keys[i%len(keys)], u, s, base.Add(time.Duration(size+i)*time.Millisecond))
+	}
+}
+
+func TestNegativeCacheEvictsOldest(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := &negativeCache{ttl: time.Minute, max: 3, entries: make(map[string]*cacheEntry)}
+	c.add("a", base)
+	c.add("b", base.Add(time.Second))
+	c.add("c", base.Add(2*time.Second))
+	c.add("a", base.Add(3*time.Second)) // re-added: now the newest
+	c.add("d", base.Add(4*time.Second)) // evicts b
+	c.delete("c")
+	c.add("e", base.Add(5*time.Second)) // room left by c
+
+	now := base.Add(6 * time.Second)
+	for key, want := range map[string]bool{"a": true, "b": false, "c": false, "d": true, "e": true} {
+		if got := c.has(key, now); got != want {
+			t.Errorf("has(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if c.has("a", base.Add(3*time.Second+time.Minute)) {
+		t.Error("has(a) after ttl = true, want false")
+	}
+	if len(c.entries) != len(c.byExpiry) {
+		t.Errorf("%d entries but %d in the heap", len(c.entries), len(c.byExpiry))
+	}
+}
+
+func BenchmarkNegativeCacheAddFull(b *testing.B) {
+	const size = 10000
+	base := time.Now()
+	c := &negativeCache{ttl: time.Minute, max: size, entries: make(map[string]*cacheEntry)}
+	keys := make([]string, 4*size)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.add(keys[i%len(keys)], base.Add(time.Duration(i)*time.Microsecond))
+	}
+}
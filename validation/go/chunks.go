@@ -0,0 +1,33 @@
+package corral
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxCookieChunks caps how many <name>.N cookies are reassembled.
+const maxCookieChunks = 16
+
+// cookieValue returns the value of the cookie called name or, when it is
+// absent, the concatenation of the chunks <name>.0, <name>.1, ... that
+// Better Auth writes for values too large for one cookie.
+func cookieValue(r *http.Request, name string) string {
+	if c, err := r.Cookie(name); err == nil {
+		return c.Value
+	}
+	return chunkedCookie(r, name)
+}
+
+// chunkedCookie reassembles <name>.0 onwards, stopping at the first gap.
+func chunkedCookie(r *http.Request, name string) string {
+	var b strings.Builder
+	for i := 0; i < maxCookieChunks; i++ {
+		c, err := r.Cookie(name + "." + strconv.Itoa(i))
+		if err != nil {
+			break
+		}
+		b.WriteString(c.Value)
+	}
+	return b.String()
+}
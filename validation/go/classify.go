@@ -0,0 +1,38 @@
+package corral
+
+// TokenKind says which verifier a Bearer token belongs to.
+type TokenKind int
+
+// Token kinds returned by a WithTokenClassifier function.
+const (
+	TokenSession TokenKind = iota // Better Auth session token (the default)
+	TokenAPIKey                   // Better Auth API key
+	TokenJWT                      // JWT verified with WithJWTVerification
+	TokenSkip                     // not ours; ignore without a lookup
+)
+
+// WithTokenClassifier routes each Bearer token to the verifier fn picks,
+// so tokens the validator cannot own (e.g. third-party OAuth access
+// tokens) are skipped without a database query. Cookies are always
+// treated as sessions. Without a classifier, JWT-shaped Bearer tokens go
+// to JWT verification when it is enabled and everything else is a
+// session.
+func WithTokenClassifier(fn func(token string) TokenKind) Option {
+	return func(v *Validator) {
+		v.classifyToken = fn
+	}
+}
+
+// classify picks the verifier for c.
+func (v *Validator) classify(c tokenCandidate) TokenKind {
+	if c.source != SourceBearer {
+		return TokenSession
+	}
+	if v.classifyToken != nil {
+		return v.classifyToken(c.token)
+	}
+	if v.jwks != nil && looksLikeJWT(c.token) {
+		return TokenJWT
+	}
+	return TokenSession
+}
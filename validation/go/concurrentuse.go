@@ -0,0 +1,54 @@
+package corral
+
+import (
+	"sync"
+	"time"
+)
+
+// WithConcurrentUseDetector calls cb when a session is used from a
+// different client IP within window of its previous use, a sign the token
+// may have been stolen. It only observes; cb can log, alert or revoke. The
+// last IP per session is kept in memory. cb runs on the request path and
+// should return quickly.
+func WithConcurrentUseDetector(window time.Duration, cb func(token, ipA, ipB string)) Option {
+	return func(v *Validator) {
+		v.useDetector = &useDetector{window: window, cb: cb, seen: make(map[string]lastUse)}
+	}
+}
+
+type lastUse struct {
+	ip string
+	at time.Time
+}
+
+// useDetector remembers the most recent IP per session token hash.
+type useDetector struct {
+	window time.Duration
+	cb     func(token, ipA, ipB string)
+
+	mu        sync.Mutex
+	seen      map[string]lastUse
+	lastSweep time.Time
+}
+
+// observe records a use of token from ip and reports a change of IP.
+func (d *useDetector) observe(token, ip string, now time.Time) {
+	key := cacheKey(token)
+
+	d.mu.Lock()
+	if now.Sub(d.lastSweep) >= d.window {
+		for k, u := range d.seen {
+			if now.Sub(u.at) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	prev, ok := d.seen[key]
+	d.seen[key] = lastUse{ip: ip, at: now}
+	d.mu.Unlock()
+
+	if ok && prev.ip != ip && now.Sub(prev.at) < d.window {
+		d.cb(token, prev.ip, ip)
+	}
+}
@@ -0,0 +1,11 @@
+package corral
+
+// WithConsistentRead runs each validation's session and user lookups in one
+// read transaction, so they see a single snapshot of the database (cheap
+// under WAL) and a user deleted between the two reads can't slip through.
+// Off by default: the race is narrow and the transaction costs a little.
+func WithConsistentRead(enabled bool) Option {
+	return func(v *Validator) {
+		v.consistentRead = enabled
+	}
+}
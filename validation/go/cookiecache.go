@@ -0,0 +1,192 @@
+package corral
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionDataCookieName is the cookie Better Auth uses for its signed
+// session cache (the cookieCache option).
+const SessionDataCookieName = "better-auth.session_data"
+
+// WithCookieCacheSecret enables Better Auth's cookieCache: requests carrying
+// a session_data cookie signed with secret (BETTER_AUTH_SECRET) and not yet
+// expired are authenticated from the cookie alone, without a DB query. Any
+// cookie that fails verification falls back to the session table. Users
+// served from the cookie get the same completion as database hits: their
+// WithUserColumns values are read from the cookie's user object (Better Auth
+// includes additionalFields there) and WithUserPostProcessor runs on them.
+func WithCookieCacheSecret(secret string) Option {
+	return func(v *Validator) {
+		if secret != "" {
+			v.cookieCacheSecret = []byte(secret)
+		}
+	}
+}
+
+// cookieCachePayload is the decoded session_data cookie. Session holds the
+// raw JSON of {"session":{...},"user":{...}} exactly as Better Auth signed it.
+type cookieCachePayload struct {
+	Session   json.RawMessage `json:"session"`
+	ExpiresAt json.Number     `json:"expiresAt"`
+	Signature string          `json:"signature"`
+}
+
+type cookieCacheData struct {
+	Session struct {
+		ID        string `json:"id"`
+		Token     string `json:"token"`
+		UserID    string `json:"userId"`
+		ExpiresAt string `json:"expiresAt"`
+		CreatedAt string `json:"createdAt"`
+	} `json:"session"`
+	User struct {
+		ID            string `json:"id"`
+		Email         string `json:"email"`
+		Name          string `json:"name"`
+		Plan          string `json:"plan"`
+		Role          string `json:"role"`
+		EmailVerified bool   `json:"emailVerified"`
+		CreatedAt     string `json:"createdAt"`
+	} `json:"user"`
+}
+
+// cookieCacheFields is the cookie's user object by field, for the
+// WithUserColumns values.
+type cookieCacheFields struct {
+	User map[string]json.RawMessage `json:"user"`
+}
+
+// userFromCookieCache returns the user and session from a valid signed
+// session_data cookie belonging to token, or nils on any miss.
+func (v *Validator) userFromCookieCache(r *http.Request, token string) (*User, *Session) {
+	if v.cookieCacheSecret == nil {
+		return nil, nil
+	}
+	for _, name := range []string{SessionDataCookieName, "__Secure-" + SessionDataCookieName} {
+		value := cookieValue(r, name)
+		if value == "" {
+			continue
+		}
+		if user, s := v.decodeCookieCache(value, token); user != nil {
+			return user, s
+		}
+	}
+	return nil, nil
+}
+
+func (v *Validator) decodeCookieCache(value, token string) (*User, *Session) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(decodeCookieValue(value), "="))
+	if err != nil {
+		return nil, nil
+	}
+	var p cookieCachePayload
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return nil, nil
+	}
+
+	// Better Auth signs JSON.stringify({...sessionData, expiresAt}), which is
+	// the session object with expiresAt appended as its last key.
+	if len(p.Session) < 2 || p.Session[len(p.Session)-1] != '}' || p.ExpiresAt == "" {
+		return nil, nil
+	}
+	signed := string(p.Session[:len(p.Session)-1]) + `,"expiresAt":` + p.ExpiresAt.String() + "}"
+	mac := hmac.New(sha256.New, v.cookieCacheSecret)
+	mac.Write([]byte(signed))
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p.Signature, "="))
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, nil
+	}
+
+	now := v.now()
+	cacheExpiry, err := p.ExpiresAt.Int64()
+	if err != nil || !now.Before(time.UnixMilli(cacheExpiry)) {
+		return nil, nil
+	}
+
+	var data cookieCacheData
+	if err := json.Unmarshal(p.Session, &data); err != nil {
+		return nil, nil
+	}
+	if !sameToken(token, data.Session.Token) || data.User.ID == "" || data.User.ID != data.Session.UserID {
+		return nil, nil
+	}
+	s := &Session{ID: data.Session.ID, Token: data.Session.Token, UserID: data.Session.UserID}
+	if s.ExpiresAt, err = parseTime(data.Session.ExpiresAt); err != nil || s.ExpiresAt.Before(now) {
+		return nil, nil
+	}
+	if s.CreatedAt, err = parseTime(data.Session.CreatedAt); err != nil || v.tooOld(s, now) {
+		return nil, nil
+	}
+	s.LongLived = s.ExpiresAt.Sub(s.CreatedAt) > v.longLivedAfter
+
+	u := &User{
+		ID:            data.User.ID,
+		Email:         data.User.Email,
+		Name:          data.User.Name,
+		Plan:          data.User.Plan,
+		Role:          data.User.Role,
+		EmailVerified: data.User.EmailVerified,
+		CreatedAt:     data.User.CreatedAt,
+	}
+	applyUserDefaults(u)
+	if len(v.userCols.requested) > 0 {
+		var fields cookieCacheFields
+		if err := json.Unmarshal(p.Session, &fields); err != nil {
+			return nil, nil
+		}
+		u.Extra = cookieExtra(fields.User, v.userCols.requested)
+	}
+	if v.postProcessUser != nil {
+		v.postProcessUser(u)
+	}
+	return u, s
+}
+
+// cookieExtra builds User.Extra from the cookie's user fields for cols,
+// formatting values as SQLite returns the stored column: strings as is,
+// booleans as 1 or 0, other values as their JSON text. Null and absent
+// fields are omitted, as NULL columns are.
+func cookieExtra(fields map[string]json.RawMessage, cols []string) map[string]string {
+	extra := make(map[string]string, len(cols))
+	for _, col := range cols {
+		raw, ok := fields[col]
+		if !ok {
+			continue
+		}
+		var val any
+		if err := json.Unmarshal(raw, &val); err != nil {
+			continue
+		}
+		switch val := val.(type) {
+		case nil:
+		case string:
+			extra[col] = val
+		case bool:
+			extra[col] = "0"
+			if val {
+				extra[col] = "1"
+			}
+		default:
+			extra[col] = string(raw)
+		}
+	}
+	return extra
+}
+
+// sameToken reports whether the request token refers to the stored session
+// token, allowing for Better Auth's signed "<token>.<signature>" cookie form.
+func sameToken(requestToken, storedToken string) bool {
+	if storedToken == "" || !strings.HasPrefix(requestToken, storedToken) {
+		return false
+	}
+	return len(requestToken) == len(storedToken) || requestToken[len(storedToken)] == '.'
+}
@@ -0,0 +1,83 @@
+package corral
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionDataCookie signs session and user the way Better Auth's cookieCache
+// does, valid until expires.
+func sessionDataCookie(t *testing.T, secret string, session, user map[string]any, expires time.Time) string {
+	t.Helper()
+	raw, err := json.Marshal(map[string]any{"session": session, "user": user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := strconv.FormatInt(expires.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(string(raw[:len(raw)-1]) + `,"expiresAt":` + ms + "}"))
+	payload := `{"session":` + string(raw) + `,"expiresAt":` + ms +
+		`,"signature":"` + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) + `"}`
+	return base64.RawURLEncoding.EncodeToString([]byte(payload))
+}
+
+func TestCookieCacheUserMatchesDatabase(t *testing.T) {
+	const secret = "better-auth-secret"
+	v, db := newTestValidator(t,
+		WithCookieCacheSecret(secret),
+		WithUserColumns("locale", "beta"),
+		WithUserPostProcessor(func(u *User) { u.Name = strings.ToUpper(u.Name) }),
+	)
+	for _, stmt := range []string{
+		`ALTER TABLE user ADD COLUMN locale TEXT`,
+		`ALTER TABLE user ADD COLUMN beta INTEGER`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertUser(t, db, "u1", "pro", "admin")
+	if _, err := db.Exec(`UPDATE user SET locale = 'de-DE', beta = 1 WHERE id = 'u1'`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	created, expires := now.Add(-time.Hour), now.Add(time.Hour)
+	insertSession(t, db, "tok1", "u1", created, expires)
+
+	cookie := sessionDataCookie(t, secret,
+		map[string]any{
+			"id": "s-tok1", "token": "tok1", "userId": "u1",
+			"createdAt": formatTime(created), "expiresAt": formatTime(expires),
+		},
+		map[string]any{
+			"id": "u1", "email": "u1@example.com", "name": "u1", "plan": "pro", "role": "admin",
+			"emailVerified": true, "createdAt": "2024-01-01T00:00:00.000Z",
+			"locale": "de-DE", "beta": true,
+		},
+		now.Add(5*time.Minute))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", SessionDataCookieName+"="+cookie)
+
+	fromCookie, _ := v.userFromCookieCache(r, "tok1")
+	if fromCookie == nil {
+		t.Fatal("cookie cache missed")
+	}
+	fromDB, _, err := v.authenticate(r.Context(), "tok1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromDB.Name != "U1" || fromDB.Extra["locale"] != "de-DE" {
+		t.Fatalf("database user = %+v, want the post-processed name and locale", fromDB)
+	}
+	if !reflect.DeepEqual(fromCookie, fromDB) {
+		t.Fatalf("cookie cache user = %+v, database user = %+v", fromCookie, fromDB)
+	}
+}
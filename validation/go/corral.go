@@ -7,7 +7,8 @@
 // to NewValidator to have it spawn `node server/auth.js` as a managed
 // subprocess. Session validation reads the DB directly and works without it.
 // Configure port via CORRAL_AUTH_PORT (default 3456) and server path via
-// CORRAL_AUTH_SERVER env var.
+// CORRAL_AUTH_SERVER env var. Setting CORRAL_AUTH_DISABLE=1 turns spawning
+// off regardless of WithAuthServer, e.g. when a sidecar owns the process.
 //
 // Usage:
 //
@@ -24,22 +25,52 @@ package corral
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 )
 
 const CookieName = "better-auth.session_token"
 
+// maxTokenCandidates caps how many duplicate session cookies are tried.
+const maxTokenCandidates = 3
+
+var (
+	// ErrNodeNotFound means the node binary is not on PATH.
+	ErrNodeNotFound = errors.New("corral: node not found on PATH")
+	// ErrAuthServerNotFound means no auth server script could be located.
+	ErrAuthServerNotFound = errors.New("corral: auth server script not found")
+)
+
+// DefaultShutdownGrace is how long Close waits after SIGTERM before killing
+// the auth server; see WithShutdownGrace.
+const DefaultShutdownGrace = 3 * time.Second
+
+// DefaultLongLivedThreshold is the session lifetime beyond which a session
+// is considered "remember me"; see WithLongLivedThreshold.
+const DefaultLongLivedThreshold = 24 * time.Hour
+
+// DefaultMaxTokenLength is the longest token validated by default; see
+// WithMaxTokenLength.
+const DefaultMaxTokenLength = 512
+
 var planLevels = map[string]int{
 	"free": 0, "pro": 1, "team": 2, "enterprise": 3,
 }
@@ -50,19 +81,57 @@ type User struct {
 	Email         string
 	Name          string
 	Plan          string
-	Role          string
+	Role          string   // primary (first) role
+	Roles         []string // every role, for comma-separated role columns
 	EmailVerified bool
 	CreatedAt     string
+	Extra         map[string]string // columns loaded with WithUserColumns
+
+	anonymous bool // the WithAnonymousUser principal, see IsAnonymous
+}
+
+// Session is a Better Auth session row.
+type Session struct {
+	ID        string
+	Token     string
+	UserID    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+
+	// Refreshed is set when validation extended ExpiresAt (sliding expiration).
+	Refreshed bool
+
+	// LongLived marks a "remember me" session: its stored lifetime
+	// (ExpiresAt - CreatedAt) exceeds the WithLongLivedThreshold threshold.
+	LongLived bool
 }
 
 type contextKey struct{}
 
-// UserFromContext extracts the User set by Middleware.
+type tokenContextKey struct{}
+
+type sessionContextKey struct{}
+
+// UserFromContext extracts the User set by Middleware or OptionalMiddleware.
 func UserFromContext(ctx context.Context) *User {
 	u, _ := ctx.Value(contextKey{}).(*User)
 	return u
 }
 
+// SessionFromContext returns the session validated by Middleware, or nil
+// for service tokens and unauthenticated requests.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return s
+}
+
+// TokenFromContext returns the session token validated by Middleware, or ""
+// unless the validator was created with WithPropagateToken(true).
+func TokenFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tokenContextKey{}).(string)
+	return t
+}
+
 // Option configures a Validator.
 type Option func(*Validator)
 
@@ -73,101 +142,582 @@ func WithAuthServer(enabled bool) Option {
 	}
 }
 
+// WithTokenDecoder sets a transform applied to every extracted token,
+// after the built-in percent-decoding of cookie values.
+func WithTokenDecoder(fn func(string) string) Option {
+	return func(v *Validator) {
+		v.tokenDecoder = fn
+	}
+}
+
+// WithUnauthorizedRedirect makes Middleware answer unauthenticated browser
+// navigations (Accept: text/html, not an XHR) with a 302 to loginURL, adding
+// a ?next= parameter pointing back at the original request. API and XHR
+// requests still get a 401.
+func WithUnauthorizedRedirect(loginURL string) Option {
+	return func(v *Validator) {
+		v.loginURL = loginURL
+	}
+}
+
+// WithRequireSecureTransport makes Middleware ignore session cookies and
+// Bearer tokens on requests that did not arrive over HTTPS, so they are
+// treated as unauthenticated. Behind a TLS-terminating proxy, list it with
+// WithTrustedProxies so its X-Forwarded-Proto is believed. Off by default
+// to keep local development easy.
+func WithRequireSecureTransport(enabled bool) Option {
+	return func(v *Validator) {
+		v.requireSecure = enabled
+	}
+}
+
+// WithMaxTokenLength rejects tokens longer than n bytes as invalid without
+// querying the database, so oversized probe headers cost nothing. n <= 0
+// disables the check. The default is DefaultMaxTokenLength.
+//
+// Such rejections are reported as ErrSessionNotFound and only show up in
+// WithFailureLogSampling: WithAuthEndpointRateLimit counts requests to
+// AuthProxyHandler, not failed validations, so repeated oversized tokens
+// are never throttled by it. Rate-limit probing clients in front of the
+// validator if that matters.
+func WithMaxTokenLength(n int) Option {
+	return func(v *Validator) {
+		v.maxTokenLength = n
+	}
+}
+
+// WithPropagateToken makes Middleware store the validated session token in
+// the request context for TokenFromContext, so handlers can forward it to
+// other Better Auth-protected services. Off by default: anything holding the
+// context can then act as the user.
+func WithPropagateToken(enabled bool) Option {
+	return func(v *Validator) {
+		v.propagateToken = enabled
+	}
+}
+
+// WithAuthServerReplicas spawns n auth server processes on consecutive
+// ports starting at CORRAL_AUTH_PORT. AuthProxyHandler round-robins across
+// the healthy ones. The default is 1.
+func WithAuthServerReplicas(n int) Option {
+	return func(v *Validator) {
+		v.authReplicas = n
+	}
+}
+
+// WithRequireNode makes a missing Node binary or auth server script fatal
+// for the managed auth server: StartAuthServer (and so NewValidatorE)
+// returns an error instead of logging and carrying on.
+func WithRequireNode(required bool) Option {
+	return func(v *Validator) {
+		v.requireNode = required
+	}
+}
+
+// WithShutdownGrace sets how long the auth server gets to exit after SIGTERM
+// before it is sent SIGKILL. The default is DefaultShutdownGrace.
+func WithShutdownGrace(d time.Duration) Option {
+	return func(v *Validator) {
+		v.shutdownGrace = d
+	}
+}
+
+// SessionValidator is the request-path surface of *Validator. Handlers can
+// depend on it instead of the concrete type and take a fake in tests.
+type SessionValidator interface {
+	ValidateSessionContext(ctx context.Context, token string) (*User, error)
+	ValidateRequest(r *http.Request) (*User, error)
+	Middleware(next http.Handler) http.Handler
+}
+
+var _ SessionValidator = (*Validator)(nil)
+
+// WithLongLivedThreshold sets the lifetime (expiresAt - createdAt) above
+// which Session.LongLived is reported, distinguishing "remember me" sessions
+// from short ones. The default is DefaultLongLivedThreshold.
+func WithLongLivedThreshold(d time.Duration) Option {
+	return func(v *Validator) {
+		v.longLivedAfter = d
+	}
+}
+
+// WithAuthServerPath sets the auth server script to spawn, skipping the
+// search for server/auth.js. StartAuthServer fails if it does not exist.
+func WithAuthServerPath(path string) Option {
+	return func(v *Validator) {
+		v.authServerPath = path
+	}
+}
+
+// WithAuthServerSearchDepth sets how many directories, starting at the DB's
+// and walking up, are searched for server/auth.js. The default is 10.
+func WithAuthServerSearchDepth(n int) Option {
+	return func(v *Validator) {
+		v.authSearchDepth = n
+	}
+}
+
+// WithCookieNames sets the session cookie names to read, e.g. to include
+// "__Secure-better-auth.session_token" or a custom cookie prefix. The first
+// name is used when writing cookies. The default is CookieName.
+func WithCookieNames(names ...string) Option {
+	return func(v *Validator) {
+		if len(names) > 0 {
+			v.cookieNames = names
+		}
+	}
+}
+
+// WithTokenExtractor replaces the built-in cookie and Bearer lookup with
+// fn, which returns the session token for r (empty if there is none) and
+// its source for tracing, such as SourceBearer. Service tokens and
+// WithRequireSecureTransport still apply.
+func WithTokenExtractor(fn func(r *http.Request) (token, source string)) Option {
+	return func(v *Validator) {
+		v.tokenExtractor = fn
+	}
+}
+
+// WithUserEnricher runs fn after a request is authenticated, before the
+// handler. The context fn returns is passed on, so it can attach per-user
+// data such as entitlements; an error answers 500.
+func WithUserEnricher(fn func(ctx context.Context, u *User) (context.Context, error)) Option {
+	return func(v *Validator) {
+		v.enrichUser = fn
+	}
+}
+
+// WithUserPostProcessor calls fn on every user loaded from the database or
+// the signed cookie cache, before it is cached or handed to a handler, to
+// derive or remap fields. Cache hits serve the already processed user.
+func WithUserPostProcessor(fn func(*User)) Option {
+	return func(v *Validator) {
+		v.postProcessUser = fn
+	}
+}
+
+// WithClock makes the validator read the current time from now instead of
+// time.Now, for expiry checks, sliding refresh, caches and rate limiting.
+// Timeouts and latency measurements still use the real clock.
+func WithClock(now func() time.Time) Option {
+	return func(v *Validator) {
+		v.clock = now
+	}
+}
+
+// WithSkipPaths lists paths Middleware lets through without authentication,
+// e.g. "/api/health". A trailing "*" matches any path with that prefix, as
+// in "/api/auth/*".
+func WithSkipPaths(paths ...string) Option {
+	return func(v *Validator) {
+		v.skipPaths = append(v.skipPaths, paths...)
+	}
+}
+
+// WithSkipFunc makes Middleware let through, without authentication, any
+// request for which skip returns true.
+func WithSkipFunc(skip func(*http.Request) bool) Option {
+	return func(v *Validator) {
+		v.skipFunc = skip
+	}
+}
+
 // Validator reads the Better Auth database to validate sessions.
 // It implements io.Closer to clean up the auth server subprocess.
 type Validator struct {
-	dbPath             string
-	authServerEnabled  bool
-	authCmd            *exec.Cmd
-	authMu             sync.Mutex
-	authStopped        bool
+	dbPath            string
+	db                *sql.DB
+	dbMu              sync.Mutex
+	authServerEnabled bool
+	authServerPath    string
+	authSearchDepth   int
+	requireNode       bool
+	authMu            sync.Mutex
+	authStopped       bool
+	authProcs         atomic.Pointer[[]*authProc]
+	authNext          atomic.Uint64 // round-robin cursor for AuthProxyHandler
+	authReplicas      int
+	shutdownGrace     time.Duration
+	tokenDecoder      func(string) string
+	loginURL          string
+	requireSecure     bool
+	serviceTokens     map[string]*User
+	limiters          planLimiters
+	maxTokenLength    int
+	cookieCacheSecret []byte
+	propagateToken    bool
+	expiresIn         time.Duration
+	updateAge         time.Duration
+	refreshCookie     bool
+	cookieOptions     CookieOptions
+	cache             Cache
+	cacheTTL          time.Duration
+	negCache          *negativeCache
+	bus               InvalidationBus
+	longLivedAfter    time.Duration
+	userHeaders       *UserHeaders
+	jwtSigner         JWTSigner
+	downstreamJWT     DownstreamJWT
+	statusCodes       map[RejectionReason]int
+	preferredSource   string
+	customQ           Querier
+	schema            Schema
+	sq                Schema // schema with defaults applied and identifiers quoted
+	configErr         error  // invalid schema or driver; fails every DB operation
+	queries           queries
+	tracer            Tracer
+	jwks              *jwksCache
+	skipPaths         []string
+	skipFunc          func(*http.Request) bool
+	cookieNames       []string
+	pwChangedCol      string
+	pwColMissing      atomic.Bool // column absent; check disabled
+	jsonErrors        bool
+	driverName        string
+	sqliteKey         string
+	slowQueryAfter    time.Duration
+	onSlowQuery       func(query string, d time.Duration)
+	tokenExtractor    func(*http.Request) (string, string)
+	enrichUser        func(context.Context, *User) (context.Context, error)
+	indexAdvisories   bool
+	banColsMissing    atomic.Bool
+	maxSessions       int
+	postProcessUser   func(*User)
+	nonces            NonceStore
+	clock             func() time.Time
+	userCols          userColumns
+	failureLogRate    float64
+	apiKeys           bool
+	scopedSessions    bool
+	pool              poolConfig
+	lockdown          atomic.Pointer[lockdown]
+	useDetector       *useDetector
+	authLimiter       *ipLimiters
+	classifyToken     func(string) TokenKind
+	waitForDB         time.Duration
+	lookupCols        []string
+	planHierarchy     atomic.Pointer[map[string]int]
+	roleHierarchy     atomic.Pointer[map[string]int]
+	activity          *activityTracker
+	userCheck         UserExistenceCheck
+	breaker           *breaker
+	snap              *snapshotter
+	anonymous         *User
+	tokenPattern      *regexp.Regexp
+	consistentRead    bool
+	features          map[string]string
+	allowUnknown      bool
+	maxSessionAge     time.Duration
+	requestIDHeader   string
+	proxyCIDRs        []string
+	trustedProxies    []netip.Prefix
+	authURL           string
+	canonicalEmail    func(string) string
+	tokenTransform    func(string) string
+	expiryWarn        time.Duration
+	onExpiring        func(*User, time.Duration)
+	userDBPath        string
+	userDB            *sql.DB
+	ownUserDB         bool // userDB was opened from userDBPath
+	authOutput        authLog
+
+	// stop is closed by Shutdown to signal background goroutines started
+	// via goBackground; bg tracks them so Shutdown can wait for exit.
+	stop     chan struct{}
+	stopOnce sync.Once
+	bg       sync.WaitGroup
 }
 
 // NewValidator creates a validator for the given SQLite database path.
+// Configuration problems are logged; use NewValidatorE to receive them.
 func NewValidator(dbPath string, opts ...Option) *Validator {
-	v := &Validator{dbPath: dbPath}
+	v, _ := newValidator(dbPath, opts)
+	return v
+}
+
+// NewValidatorE is like NewValidator but returns an error for an invalid
+// schema or a failed auth server start. Combined with WithRequireNode, a
+// missing Node binary or auth server script is also an error.
+func NewValidatorE(dbPath string, opts ...Option) (*Validator, error) {
+	v, err := newValidator(dbPath, opts)
+	if err != nil {
+		_ = v.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+func newValidator(dbPath string, opts []Option) (*Validator, error) {
+	v := &Validator{
+		dbPath:          dbPath,
+		maxTokenLength:  DefaultMaxTokenLength,
+		shutdownGrace:   DefaultShutdownGrace,
+		longLivedAfter:  DefaultLongLivedThreshold,
+		authSearchDepth: 10,
+		cookieNames:     []string{CookieName},
+		driverName:      defaultDriver,
+		tokenPattern:    DefaultTokenPattern,
+		stop:            make(chan struct{}),
+	}
 	for _, o := range opts {
 		o(v)
 	}
-	if v.authServerEnabled {
-		v.StartAuthServer()
+	v.sq, v.configErr = v.schema.resolve()
+	var lookupCols []string
+	if v.configErr == nil {
+		lookupCols, v.configErr = resolveLookupCols(v.sq, v.lookupCols)
 	}
-	return v
+	v.queries = buildQueries(v.sq, lookupCols)
+	if v.configErr == nil {
+		v.trustedProxies, v.configErr = parsePrefixes(v.proxyCIDRs)
+	}
+	if v.configErr == nil {
+		v.configErr = v.checkDriver()
+	}
+	if v.configErr == nil {
+		v.configErr = v.checkPreferredSource()
+	}
+	if v.configErr != nil {
+		log.Printf("[corral] %v", v.configErr)
+		return v, v.configErr
+	}
+	if v.indexAdvisories {
+		v.adviseIndexes(context.Background())
+	}
+	if v.bus != nil {
+		v.subscribeInvalidations()
+	}
+	if v.activity != nil {
+		v.startActivityFlusher()
+	}
+	if v.snap != nil && v.snap.interval > 0 {
+		v.startSnapshots()
+	}
+	if v.authURL != "" {
+		if err := v.attachAuthServer(); err != nil {
+			return v, err
+		}
+	} else if v.authServerEnabled {
+		if err := v.StartAuthServer(); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
 }
 
 // StartAuthServer spawns the Node auth server as a managed subprocess.
-// It blocks until the health check passes or 5s timeout.
-func (v *Validator) StartAuthServer() {
+// It blocks until the health check passes or 5s timeout. A missing script or
+// Node binary is logged and skipped unless WithRequireNode is set, in which
+// case ErrAuthServerNotFound or ErrNodeNotFound is returned. An explicit
+// WithAuthServerPath that does not exist, or a failed spawn, is always an
+// error. A process that exits before becoming healthy is reported at once
+// as an *AuthServerExitError. CORRAL_AUTH_DISABLE=1 makes it a logged no-op.
+func (v *Validator) StartAuthServer() error {
 	v.authMu.Lock()
 	defer v.authMu.Unlock()
 
+	if disabled, _ := strconv.ParseBool(os.Getenv("CORRAL_AUTH_DISABLE")); disabled {
+		log.Println("[corral-auth] Spawning disabled by CORRAL_AUTH_DISABLE")
+		return nil
+	}
+
 	port := os.Getenv("CORRAL_AUTH_PORT")
 	if port == "" {
 		port = "3456"
 	}
 
-	serverPath := v.findAuthServer()
+	serverPath, err := v.findAuthServer()
+	if err != nil {
+		log.Printf("[corral-auth] %v", err)
+		return err
+	}
 	if serverPath == "" {
+		if v.requireNode {
+			log.Println("[corral-auth] server/auth.js not found")
+			return ErrAuthServerNotFound
+		}
 		log.Println("[corral-auth] server/auth.js not found — auth operations won't work, session validation still works")
-		return
+		return nil
 	}
 
 	// Check node is available
 	if _, err := exec.LookPath("node"); err != nil {
+		if v.requireNode {
+			log.Println("[corral-auth] Node.js not installed")
+			return ErrNodeNotFound
+		}
 		log.Println("[corral-auth] Node.js not installed — skipping auth server spawn")
-		return
+		return nil
 	}
 
-	cmd := exec.Command("node", serverPath)
-	cmd.Env = append(os.Environ(), "AUTH_PORT="+port)
-	cmd.Stdout = &prefixWriter{prefix: "[corral-auth] ", logFn: log.Printf}
-	cmd.Stderr = &prefixWriter{prefix: "[corral-auth] ", logFn: log.Printf}
-	// Use process group so we can kill the tree
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-
-	if err := cmd.Start(); err != nil {
-		log.Printf("[corral-auth] Failed to spawn auth server: %v", err)
-		return
+	base, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("corral: invalid auth server port %q", port)
+	}
+	replicas := max(v.authReplicas, 1)
+	procs := make([]*authProc, 0, replicas)
+	for i := 0; i < replicas; i++ {
+		p, err := spawnAuthServer(serverPath, strconv.Itoa(base+i), &v.authOutput)
+		if err != nil {
+			// Close tears down any replicas already running.
+			v.authProcs.Store(&procs)
+			return err
+		}
+		procs = append(procs, p)
+	}
+	v.authProcs.Store(&procs)
+	if v.authOutput.limit > 0 {
+		v.startAuthLogSummaries()
 	}
-	v.authCmd = cmd
 
 	// Health check
-	url := fmt.Sprintf("http://localhost:%s/api/auth/ok", port)
 	client := &http.Client{Timeout: time.Second}
 	deadline := time.Now().Add(5 * time.Second)
-	healthy := false
-	for time.Now().Before(deadline) {
-		resp, err := client.Get(url)
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == 200 {
-				healthy = true
+	for _, p := range procs {
+		for time.Now().Before(deadline) {
+			if probeAuthServer(client, p.healthURL()) {
+				p.healthy.Store(true)
 				break
 			}
+			select {
+			case <-p.exited:
+				err := p.startupExitError()
+				log.Printf("[corral-auth] %v", err)
+				return err
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		if p.healthy.Load() {
+			log.Printf("[corral-auth] Auth server ready on port %s (pid %d)", p.port, p.cmd.Process.Pid)
+		} else {
+			log.Printf("[corral-auth] Auth server on port %s health check failed after 5s — it may still be starting", p.port)
 		}
-		time.Sleep(100 * time.Millisecond)
+		v.goBackground(func(stop <-chan struct{}) {
+			probeAuthServerLoop(stop, p, client)
+		})
 	}
+	return nil
+}
 
-	if healthy {
-		log.Printf("[corral-auth] Auth server ready on port %s (pid %d)", port, cmd.Process.Pid)
-	} else {
-		log.Println("[corral-auth] Auth server health check failed after 5s — it may still be starting")
+// authProc is one managed auth server process.
+type authProc struct {
+	cmd     *exec.Cmd
+	port    string
+	base    string // e.g. "http://localhost:3456"
+	stderr  *prefixWriter
+	exited  chan struct{} // closed once the process has been reaped
+	waitErr error         // set before exited is closed
+	healthy atomic.Bool
+}
+
+// authStderrTail is how many stderr lines are kept for exit errors.
+const authStderrTail = 20
+
+// exitError describes an abnormal exit, with the tail of stderr. It is nil
+// if the process is running or exited cleanly.
+func (p *authProc) exitError() error {
+	select {
+	case <-p.exited:
+	default:
+		return nil
+	}
+	if p.waitErr == nil {
+		return nil
+	}
+	tail := p.stderr.tail()
+	if len(tail) == 0 {
+		return fmt.Errorf("corral: auth server on port %s exited: %w", p.port, p.waitErr)
 	}
+	return fmt.Errorf("corral: auth server on port %s exited: %w\n%s", p.port, p.waitErr, strings.Join(tail, "\n"))
 }
 
-func (v *Validator) findAuthServer() string {
+// AuthServerExitError is returned by StartAuthServer when the auth server
+// process exits before passing its health check, typically because the
+// script crashed on startup.
+type AuthServerExitError struct {
+	Port     string
+	ExitCode int      // -1 if the process was killed by a signal
+	Stderr   []string // last lines the process wrote to stderr
+	Err      error    // from cmd.Wait; nil for a clean exit
+}
+
+func (e *AuthServerExitError) Error() string {
+	msg := fmt.Sprintf("corral: auth server on port %s exited during startup (code %d)", e.Port, e.ExitCode)
+	if len(e.Stderr) > 0 {
+		msg += "\n" + strings.Join(e.Stderr, "\n")
+	}
+	return msg
+}
+
+func (e *AuthServerExitError) Unwrap() error { return e.Err }
+
+// startupExitError describes an exited process; p.exited must be closed.
+func (p *authProc) startupExitError() *AuthServerExitError {
+	return &AuthServerExitError{
+		Port:     p.port,
+		ExitCode: p.cmd.ProcessState.ExitCode(),
+		Stderr:   p.stderr.tail(),
+		Err:      p.waitErr,
+	}
+}
+
+func (p *authProc) healthURL() string {
+	return p.base + "/api/auth/ok"
+}
+
+func spawnAuthServer(serverPath, port string, out *authLog) (*authProc, error) {
+	cmd := exec.Command("node", serverPath)
+	cmd.Env = append(os.Environ(), "AUTH_PORT="+port)
+	stderr := &prefixWriter{prefix: "[corral-auth] ", logFn: log.Printf, keep: authStderrTail, acct: out}
+	cmd.Stdout = &prefixWriter{prefix: "[corral-auth] ", logFn: log.Printf, acct: out}
+	cmd.Stderr = stderr
+	// Use process group so we can kill the tree
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		log.Printf("[corral-auth] Failed to spawn auth server: %v", err)
+		return nil, fmt.Errorf("corral: spawn auth server: %w", err)
+	}
+	p := &authProc{
+		cmd:    cmd,
+		port:   port,
+		base:   "http://localhost:" + port,
+		stderr: stderr,
+		exited: make(chan struct{}),
+	}
+
+	// Single owner of cmd.Wait; stopAuthServer waits on exited.
+	go func() {
+		p.waitErr = cmd.Wait()
+		p.healthy.Store(false)
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// findAuthServer locates the auth server script: the WithAuthServerPath
+// path, else CORRAL_AUTH_SERVER, else server/auth.js in the DB's directory or
+// one of its ancestors. It returns "" if nothing is found, and an error only
+// when an explicitly configured path is missing.
+func (v *Validator) findAuthServer() (string, error) {
+	if v.authServerPath != "" {
+		if _, err := os.Stat(v.authServerPath); err != nil {
+			return "", fmt.Errorf("corral: auth server script %s: %w", v.authServerPath, err)
+		}
+		return v.authServerPath, nil
+	}
 	if p := os.Getenv("CORRAL_AUTH_SERVER"); p != "" {
 		if _, err := os.Stat(p); err == nil {
-			return p
+			return p, nil
 		}
-		return ""
+		return "", nil
 	}
 	dir, _ := filepath.Abs(v.dbPath)
 	dir = filepath.Dir(dir)
-	for i := 0; i < 10; i++ {
+	for i := 0; i < v.authSearchDepth; i++ {
 		candidate := filepath.Join(dir, "server", "auth.js")
 		if _, err := os.Stat(candidate); err == nil {
-			return candidate
+			return candidate, nil
 		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
@@ -175,42 +725,141 @@ func (v *Validator) findAuthServer() string {
 		}
 		dir = parent
 	}
-	return ""
+	return "", nil
 }
 
-// Close stops the auth server subprocess gracefully (SIGTERM, then SIGKILL after 3s).
+// goBackground runs fn in a goroutine tracked by Shutdown. fn must return
+// promptly once stop is closed.
+func (v *Validator) goBackground(fn func(stop <-chan struct{})) {
+	v.bg.Add(1)
+	go func() {
+		defer v.bg.Done()
+		fn(v.stop)
+	}()
+}
+
+// Shutdown stops background goroutines and the auth server subprocess, then
+// closes the shared DB pool.
+// The subprocess receives SIGTERM and is escalated to SIGKILL if it has
+// not exited when ctx is done or the grace period set by WithShutdownGrace
+// elapses, whichever comes first. It returns ctx.Err() if background
+// goroutines did not finish before ctx was done.
+func (v *Validator) Shutdown(ctx context.Context) error {
+	v.stopOnce.Do(func() { close(v.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		v.bg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if aerr := v.stopAuthServer(ctx); err == nil {
+		err = aerr
+	}
+
+	v.dbMu.Lock()
+	if v.db != nil {
+		if cerr := v.db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if v.userDB != nil && v.ownUserDB {
+		if cerr := v.userDB.Close(); err == nil {
+			err = cerr
+		}
+	}
+	v.dbMu.Unlock()
+	return err
+}
+
+// Close stops the auth server subprocess gracefully (SIGTERM, then SIGKILL
+// after the shutdown grace period, 3s by default). It is equivalent to
+// Shutdown with a context that times out after the grace period.
 func (v *Validator) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), v.shutdownGrace)
+	defer cancel()
+	return v.Shutdown(ctx)
+}
+
+func (v *Validator) stopAuthServer(ctx context.Context) error {
 	v.authMu.Lock()
 	defer v.authMu.Unlock()
 
-	if v.authStopped || v.authCmd == nil || v.authCmd.Process == nil {
+	procs := v.authServers()
+	if v.authStopped || len(procs) == 0 {
 		return nil
 	}
 	v.authStopped = true
-	cmd := v.authCmd
-
-	log.Printf("[corral-auth] Stopping auth server (pid %d)", cmd.Process.Pid)
 
-	// SIGTERM to process group
-	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	// Report processes that died on their own; those we signal below exit
+	// non-zero by design.
+	var err error
+	for _, p := range procs {
+		if p.cmd == nil {
+			continue
+		}
+		if perr := p.exitError(); perr != nil {
+			if err == nil {
+				err = perr
+			}
+			continue
+		}
+		log.Printf("[corral-auth] Stopping auth server (pid %d)", p.cmd.Process.Pid)
+		// SIGTERM to process group
+		_ = syscall.Kill(-p.cmd.Process.Pid, syscall.SIGTERM)
+	}
 
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
+	ctx, cancel := context.WithTimeout(ctx, v.shutdownGrace)
+	defer cancel()
+	for _, p := range procs {
+		if p.cmd == nil {
+			continue
+		}
+		select {
+		case <-p.exited:
+		case <-ctx.Done():
+			_ = syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+			<-p.exited
+		}
+	}
+	return err
+}
 
-	select {
-	case <-done:
-	case <-time.After(3 * time.Second):
-		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-		<-done
+// authServers returns the managed auth server processes, if any.
+func (v *Validator) authServers() []*authProc {
+	if procs := v.authProcs.Load(); procs != nil {
+		return *procs
 	}
 	return nil
 }
 
-// prefixWriter is a simple io.Writer that logs lines with a prefix.
+// prefixWriter is a simple io.Writer that logs lines with a prefix. With
+// keep > 0 it also retains the last keep lines for tail.
 type prefixWriter struct {
 	prefix string
 	logFn  func(string, ...any)
 	buf    []byte
+	keep   int
+	acct   *authLog // counts and rate-limits lines, if set
+
+	mu    sync.Mutex
+	lines []string
+}
+
+// maxTailLine caps each retained line so the tail stays bounded.
+const maxTailLine = 1024
+
+// tail returns a copy of the retained lines, oldest first.
+func (w *prefixWriter) tail() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.lines)
 }
 
 func (w *prefixWriter) Write(p []byte) (int, error) {
@@ -229,109 +878,902 @@ func (w *prefixWriter) Write(p []byte) (int, error) {
 		line := strings.TrimRight(string(w.buf[:idx]), "\r")
 		w.buf = w.buf[idx+1:]
 		if line != "" {
-			w.logFn("%s%s", w.prefix, line)
+			if w.acct == nil || w.acct.allow(time.Now()) {
+				w.logFn("%s%s", w.prefix, line)
+			}
+			if w.keep > 0 {
+				w.retain(line)
+			}
 		}
 	}
 	return len(p), nil
 }
 
+func (w *prefixWriter) retain(line string) {
+	if len(line) > maxTailLine {
+		line = line[:maxTailLine]
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.lines) == w.keep {
+		w.lines = slices.Delete(w.lines, 0, 1)
+	}
+	w.lines = append(w.lines, line)
+}
+
+// DB returns the validator's shared connection pool, so advanced read-only
+// queries against the auth database can reuse it instead of opening a second
+// pool on the same file. Callers must not close it; Close does. DB returns
+// nil if the pool cannot be opened, or with WithQuerier unless the Querier
+// is itself a *sql.DB.
+func (v *Validator) DB() *sql.DB {
+	if v.customQ != nil {
+		db, _ := v.customQ.(*sql.DB)
+		return db
+	}
+	db, _ := v.open()
+	return db
+}
+
+// open returns the shared pool, opening it on first use.
 func (v *Validator) open() (*sql.DB, error) {
-	return sql.Open("sqlite", v.dbPath)
+	if v.configErr != nil {
+		return nil, v.configErr
+	}
+	v.dbMu.Lock()
+	defer v.dbMu.Unlock()
+	if v.db == nil {
+		db, err := sql.Open(v.driverName, v.dsn())
+		if err != nil {
+			return nil, err
+		}
+		v.pool.apply(db)
+		v.db = db
+	}
+	return v.db, nil
+}
+
+// Warmup opens the connection pool and runs the session and user queries
+// once, so the first real request doesn't pay for opening the database.
+// Call it from a readiness probe; it is safe to call repeatedly. With
+// WithWaitForDB it first waits for the database to appear.
+func (v *Validator) Warmup(ctx context.Context) error {
+	if v.waitForDB > 0 {
+		if err := v.awaitDB(ctx); err != nil {
+			return err
+		}
+	}
+	db, err := v.querier()
+	if err != nil {
+		return err
+	}
+	if err := ping(ctx, db); err != nil {
+		return fmt.Errorf("corral: warmup: %w", err)
+	}
+	if _, err := v.lookupSession(ctx, db, "", v.now()); err != nil {
+		return fmt.Errorf("corral: warmup: %w", err)
+	}
+	users, err := v.usersQueryer(db)
+	if err != nil {
+		return err
+	}
+	if _, err := v.getUserByID(ctx, users, ""); err != nil {
+		return fmt.Errorf("corral: warmup: %w", err)
+	}
+	return nil
 }
 
 // ValidateSession looks up a session token, checks expiry, returns the User.
 func (v *Validator) ValidateSession(token string) (*User, error) {
-	db, err := v.open()
+	return v.ValidateSessionContext(context.Background(), token)
+}
+
+// ValidateSessionContext is like ValidateSession but honors ctx for the DB queries.
+func (v *Validator) ValidateSessionContext(ctx context.Context, token string) (*User, error) {
+	ctx, end := v.startTrace(ctx)
+	user, s, err := v.authenticate(ctx, token)
+	if isRejection(err) {
+		err = nil
+	}
+	if err == nil {
+		v.warnExpiry(user, s)
+	}
+	end(authResult{user: user, session: s, token: token, source: SourceArgument}, err)
+	return user, err
+}
+
+// ValidateSessionFull is like ValidateSessionContext but also returns the
+// session. With WithSlidingExpiration, Session.Refreshed reports whether this
+// call extended expiresAt, so callers can re-issue the cookie.
+func (v *Validator) ValidateSessionFull(ctx context.Context, token string) (*User, *Session, error) {
+	u, s, err := v.authenticate(ctx, token)
+	if isRejection(err) {
+		return nil, nil, nil
+	}
+	if err == nil {
+		v.warnExpiry(u, s)
+	}
+	return u, s, err
+}
+
+// ResolveUserID returns the user ID of token's session with a single query,
+// skipping the user lookup (and therefore ban checks, post-processing and
+// sliding refresh). It returns "" and a nil error if the session is
+// missing, expired or past WithAbsoluteMaxSessionAge. Caches, the negative
+// cache and the circuit breaker apply as in ValidateSession.
+func (v *Validator) ResolveUserID(ctx context.Context, token string) (string, error) {
+	now := v.now()
+	key, u, _, err := v.beforeDB(ctx, token, now)
+	if isRejection(err) {
+		return "", nil
+	}
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if u != nil {
+		return u.ID, nil
+	}
+	db, err := v.querier()
+	if err != nil {
+		return "", err
+	}
+	s, err := v.lookupSession(ctx, db, token, now)
+	err = dbError(err)
+	if v.breaker != nil {
+		v.breaker.record(err, now)
+	}
+	if err != nil {
+		return "", err
 	}
-	defer db.Close()
+	if s == nil || s.ExpiresAt.Before(now) || v.tooOld(s, now) {
+		if v.negCache != nil {
+			v.negCache.add(key, now)
+		}
+		return "", nil
+	}
+	return s.UserID, nil
+}
 
-	var userID string
-	var expiresAt string
-	err = db.QueryRow(
-		`SELECT "userId", "expiresAt" FROM "session" WHERE "token" = ?`, token,
-	).Scan(&userID, &expiresAt)
+// IsSessionFresh reports whether token belongs to a valid session that was
+// created no more than within ago ("sudo mode" for sensitive actions).
+// Missing and expired sessions are never fresh.
+func (v *Validator) IsSessionFresh(ctx context.Context, token string, within time.Duration) (bool, error) {
+	_, s, err := v.authenticate(ctx, token)
+	if isRejection(err) {
+		return false, nil
+	}
+	if err != nil || s == nil {
+		return false, err
+	}
+	return v.now().Sub(s.CreatedAt) <= within, nil
+}
+
+// authenticate validates token and returns the user along with its session.
+// A missing or expired session is reported as a rejection reason
+// (ErrSessionNotFound, ErrSessionExpired); see isRejection.
+func (v *Validator) authenticate(ctx context.Context, token string) (*User, *Session, error) {
+	// One reading of the clock for the whole validation, so the expiry
+	// check, refresh and caches agree.
+	now := v.now()
+	key, u, s, err := v.beforeDB(ctx, token, now)
+	if u != nil || err != nil {
+		return u, s, err
+	}
+	pool := v.DB()
+	u, s, err = v.authenticateDB(ctx, token, now)
+	if err != nil && isStaleDB(err) {
+		// The file may have been moved or replaced under a long-lived
+		// pool: retry once on a fresh one.
+		v.reopen(pool)
+		if u, s, err = v.authenticateDB(ctx, token, now); err == nil {
+			log.Println("[corral] Reopened database pool after the file changed")
+		}
+	}
+	err = dbError(err)
+	if v.breaker != nil {
+		v.breaker.record(err, now)
+	}
+	switch {
+	case isRejection(err):
+		if v.negCache != nil {
+			v.negCache.add(key, now)
+		}
+	case err != nil:
+	case v.cache != nil:
+		v.cacheSet(key, u, s, now)
+	}
+	return u, s, err
+}
+
+// beforeDB runs the checks that precede the session query: token shape,
+// the session cache and snapshot, the negative cache and the circuit
+// breaker. It returns the cached user and session on a hit, or an error
+// (a rejection or ErrCircuitOpen); with neither, the caller queries the
+// database and key is the token's cache key.
+func (v *Validator) beforeDB(ctx context.Context, token string, now time.Time) (key string, u *User, s *Session, err error) {
+	if v.maxTokenLength > 0 && len(token) > v.maxTokenLength {
+		return "", nil, nil, ErrSessionNotFound
+	}
+	if v.tokenPattern != nil && !v.tokenPattern.MatchString(token) {
+		return "", nil, nil, ErrSessionNotFound
+	}
+	if v.cache != nil || v.negCache != nil || v.snap != nil {
+		key = v.sessionKey(token)
+	}
+	if u, s, ok := v.cachedSession(key, now); ok {
+		if v.tooOld(s, now) {
+			v.dropCached(key, now)
+			return key, nil, nil, ErrSessionExpired
+		}
+		if v.userCheck != CheckUserAlways {
+			return key, u, s, nil
+		}
+		exists, err := v.userExists(ctx, u.ID)
+		if err != nil {
+			return key, nil, nil, dbError(err)
+		}
+		if exists {
+			return key, u, s, nil
+		}
+		v.dropCached(key, now)
+		return key, nil, nil, ErrSessionNotFound
+	}
+	if v.negCache != nil && v.negCache.has(key, now) {
+		return key, nil, nil, ErrSessionNotFound
+	}
+	if v.breaker != nil && !v.breaker.allow(now) {
+		return key, nil, nil, ErrCircuitOpen
+	}
+	return key, nil, nil, nil
+}
+
+// authenticateDB validates token against the database as of now, bypassing
+// caches.
+func (v *Validator) authenticateDB(ctx context.Context, token string, now time.Time) (*User, *Session, error) {
+	db, err := v.querier()
+	if err != nil {
+		return nil, nil, err
+	}
+	var u *User
+	var s *Session
+	if v.consistentRead {
+		tx, err := begin(ctx, db, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, nil, err
+		}
+		u, s, err = v.checkSession(ctx, tx, token, now)
+		// Ends the read before maybeRefresh writes.
+		tx.Rollback()
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if u, s, err = v.checkSession(ctx, db, token, now); err != nil {
+		return nil, nil, err
+	}
+	v.maybeRefresh(ctx, db, s, now)
+	return u, s, nil
+}
+
+// checkSession runs the read-only part of authenticateDB against db.
+func (v *Validator) checkSession(ctx context.Context, db Querier, token string, now time.Time) (*User, *Session, error) {
+	s, err := v.lookupSession(ctx, db, token, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, ErrSessionNotFound
+	}
+	if s.ExpiresAt.Before(now) || v.tooOld(s, now) {
+		return nil, nil, ErrSessionExpired
+	}
+
+	db, err = v.usersQueryer(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, ban, err := v.getUserWithBan(ctx, db, s.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, ErrSessionNotFound
+	}
+	stale, err := v.predatesCredentialChange(ctx, db, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stale {
+		return nil, nil, ErrSessionExpired
+	}
+	if ban.activeAt(u.ID, now) {
+		return nil, nil, ErrUserBanned
+	}
+	return u, s, nil
+}
+
+// lookupSession fetches the session row for token, or nil if there is none.
+// With several WithSessionLookupColumns it returns the first unexpired
+// match as of now, else the last expired one. Expiry is not otherwise
+// checked.
+func (v *Validator) lookupSession(ctx context.Context, db Querier, token string, now time.Time) (*Session, error) {
+	var expired *Session
+	token = v.dbToken(token)
+	for _, query := range v.queries.selectSession {
+		s, err := v.querySession(ctx, db, query, token)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			continue
+		}
+		if s.ExpiresAt.After(now) {
+			return s, nil
+		}
+		expired = s
+	}
+	return expired, nil
+}
+
+// querySession runs one session lookup query. Rows with a NULL userId or
+// expiresAt (bad imports) are logged and treated as missing; a NULL
+// createdAt makes the session look infinitely old.
+func (v *Validator) querySession(ctx context.Context, db Querier, query, token string) (*Session, error) {
+	s := &Session{}
+	var storedToken, userID, expiresAt, createdAt sql.NullString
+	done := v.timeQuery(QuerySession)
+	err := db.QueryRowContext(ctx, query, token).
+		Scan(&s.ID, &storedToken, &userID, &expiresAt, &createdAt)
+	done()
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	if !userID.Valid || !expiresAt.Valid {
+		log.Printf("[corral] Session %s has NULL userId or expiresAt; treating as invalid", s.ID)
+		return nil, nil
+	}
+	s.Token = storedToken.String
+	s.UserID = userID.String
+	if s.ExpiresAt, err = parseTime(expiresAt.String); err != nil {
+		return nil, err
+	}
+	if createdAt.Valid {
+		if s.CreatedAt, err = parseTime(createdAt.String); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("[corral] Session %s has NULL createdAt", s.ID)
+	}
+	s.LongLived = s.ExpiresAt.Sub(s.CreatedAt) > v.longLivedAfter
+	return s, nil
+}
 
-	exp, err := time.Parse(time.RFC3339, expiresAt)
+// parseTime parses the timestamp formats Better Auth writes to SQLite.
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
 	if err != nil {
 		// Try alternate format
-		exp, err = time.Parse("2006-01-02 15:04:05", expiresAt)
+		t, err = time.Parse("2006-01-02 15:04:05", value)
 		if err != nil {
-			return nil, err
+			return time.Time{}, err
 		}
-		exp = exp.UTC()
-	}
-	if exp.Before(time.Now().UTC()) {
-		return nil, nil
 	}
+	return t.UTC(), nil
+}
 
-	return v.GetUserByID(db, userID)
+func (v *Validator) now() time.Time {
+	if v.clock != nil {
+		return v.clock().UTC()
+	}
+	return time.Now().UTC()
 }
 
-// GetUserByID fetches a user by ID from the given db connection.
+// GetUserByID fetches a user by ID from the given db connection, or from
+// the WithUserDB database if one is configured.
 func (v *Validator) GetUserByID(db *sql.DB, userID string) (*User, error) {
-	u := &User{}
-	var name, plan, role sql.NullString
-	var verified sql.NullBool
-	err := db.QueryRow(
-		`SELECT "id","email","name","plan","role","emailVerified","createdAt" FROM "user" WHERE "id" = ?`, userID,
-	).Scan(&u.ID, &u.Email, &name, &plan, &role, &verified, &u.CreatedAt)
+	users, err := v.usersQueryer(db)
+	if err != nil {
+		return nil, err
+	}
+	return v.getUserByID(context.Background(), users, userID)
+}
+
+func (v *Validator) getUserByID(ctx context.Context, db Querier, userID string) (*User, error) {
+	done := v.timeQuery(QueryUser)
+	u, err := scanUser(db.QueryRowContext(ctx, v.queries.selectUser, userID))
+	done()
+	return v.finishUser(ctx, db, u, err)
+}
+
+// finishUser completes a user scanned from db: nil for sql.ErrNoRows,
+// otherwise the WithUserColumns values and the WithUserPostProcessor hook.
+func (v *Validator) finishUser(ctx context.Context, db Querier, u *User, err error) (*User, error) {
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	if err := v.loadUserColumns(ctx, db, u); err != nil {
+		return nil, err
+	}
+	if v.postProcessUser != nil {
+		v.postProcessUser(u)
+	}
+	return u, nil
+}
+
+// scanUser reads a user row selected with the columns of selectUser into a
+// new User, and any further columns into extra.
+func scanUser(row interface{ Scan(...any) error }, extra ...any) (*User, error) {
+	u := &User{}
+	var email, name, plan, role, createdAt sql.NullString
+	var verified sql.NullBool
+	dest := append([]any{&u.ID, &email, &name, &plan, &role, &verified, &createdAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if !email.Valid || !createdAt.Valid {
+		log.Printf("[corral] User %s has NULL email or createdAt", u.ID)
+	}
+	u.Email = email.String
+	u.CreatedAt = createdAt.String
 	u.Name = name.String
 	u.Plan = plan.String
+	u.Role = role.String
+	u.EmailVerified = verified.Bool
+	applyUserDefaults(u)
+	return u, nil
+}
+
+// applyUserDefaults normalizes plan and role (see normalizeLevel), splits a
+// comma-separated role into Roles, and fills in the ones Better Auth leaves
+// empty.
+func applyUserDefaults(u *User) {
+	u.Plan = normalizeLevel(u.Plan)
 	if u.Plan == "" {
 		u.Plan = "free"
 	}
-	u.Role = role.String
-	if u.Role == "" {
-		u.Role = "user"
+	u.Roles = parseRoles(u.Role)
+	if len(u.Roles) == 0 {
+		u.Roles = []string{"user"}
 	}
-	u.EmailVerified = verified.Bool
-	return u, nil
+	u.Role = u.Roles[0]
 }
 
-// RequirePlan checks if the user's plan meets the minimum.
+// parseRoles splits a role column value such as "admin,user" as Better
+// Auth's admin plugin stores it.
+func parseRoles(role string) []string {
+	var roles []string
+	for _, r := range strings.Split(role, ",") {
+		if r = normalizeLevel(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// RequirePlan checks if the user's plan meets the minimum under the default
+// plan ordering. Plans compare case-insensitively, ignoring surrounding
+// space. See Validator.RequirePlan for a reloadable hierarchy.
 func RequirePlan(user *User, plan string) bool {
-	return planLevels[user.Plan] >= planLevels[plan]
+	return planLevels[normalizeLevel(user.Plan)] >= planLevels[normalizeLevel(plan)]
+}
+
+// normalizeLevel canonicalizes a plan or role name for comparison.
+func normalizeLevel(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
 }
 
-func extractToken(r *http.Request) string {
-	if c, err := r.Cookie(CookieName); err == nil && c.Value != "" {
-		return c.Value
+// tokenCandidate is a token found on a request and where it was found.
+type tokenCandidate struct {
+	token  string
+	source string
+}
+
+// tokensFromRequest returns the candidate session tokens for r, or nil if
+// there are none or they may not be honored over this transport.
+func (v *Validator) tokensFromRequest(r *http.Request) []tokenCandidate {
+	var tokens []tokenCandidate
+	if v.tokenExtractor != nil {
+		if token, source := v.tokenExtractor(r); token != "" {
+			tokens = []tokenCandidate{{token: token, source: source}}
+		}
+	} else {
+		tokens = v.extractTokens(r)
+	}
+	if len(tokens) > 0 && v.requireSecure && !v.isSecureRequest(r) {
+		log.Printf("[corral] Ignoring session token sent over plain HTTP from %s", r.RemoteAddr)
+		return nil
+	}
+	return tokens
+}
+
+// extractTokens returns the cookie tokens (see cookieTokens), falling back
+// to the Bearer token, or the other way round with
+// WithPreferredTokenSource(SourceBearer).
+func (v *Validator) extractTokens(r *http.Request) []tokenCandidate {
+	if v.preferredSource == SourceBearer {
+		if tokens := v.bearerTokens(r); len(tokens) > 0 {
+			return tokens
+		}
+		return v.cookieTokens(r)
+	}
+	if tokens := v.cookieTokens(r); len(tokens) > 0 {
+		return tokens
+	}
+	return v.bearerTokens(r)
+}
+
+// cookieTokens returns the values of every session cookie on r, in header
+// order and capped at maxTokenCandidates, since a stale host-only cookie can
+// share its name with the domain-wide one. Without session cookies it tries
+// chunked ones (<name>.0, <name>.1, ...).
+func (v *Validator) cookieTokens(r *http.Request) []tokenCandidate {
+	if !v.mayHaveSessionCookie(r) {
+		return nil
 	}
-	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
-		return auth[7:]
+	var tokens []tokenCandidate
+	for _, c := range r.Cookies() {
+		if len(tokens) == maxTokenCandidates {
+			break
+		}
+		if c.Value == "" || !v.isSessionCookie(c.Name) {
+			continue
+		}
+		token := v.cleanToken(decodeCookieValue(c.Value))
+		if token == "" || slices.ContainsFunc(tokens, func(t tokenCandidate) bool { return t.token == token }) {
+			continue
+		}
+		tokens = append(tokens, tokenCandidate{token: token, source: SourceCookie})
+	}
+	if len(tokens) == 0 {
+		// Only chunked cookies, which Better Auth writes in place of the
+		// base cookie when the value is too large.
+		for _, name := range v.cookieNames {
+			if token := v.cleanToken(decodeCookieValue(chunkedCookie(r, name))); token != "" {
+				tokens = append(tokens, tokenCandidate{token: token, source: SourceCookie})
+				break
+			}
+		}
+	}
+	return tokens
+}
+
+// bearerTokens returns the Bearer token of r, if any.
+func (v *Validator) bearerTokens(r *http.Request) []tokenCandidate {
+	if token := v.cleanToken(bearerToken(r.Header.Get("Authorization"))); token != "" {
+		return []tokenCandidate{{token: token, source: SourceBearer}}
+	}
+	return nil
+}
+
+func (v *Validator) isSessionCookie(name string) bool {
+	return slices.Contains(v.cookieNames, name)
+}
+
+// cleanToken applies WithTokenDecoder and returns "" for malformed tokens.
+func (v *Validator) cleanToken(token string) string {
+	if token != "" && v.tokenDecoder != nil {
+		token = v.tokenDecoder(token)
+	}
+	if !wellFormedToken(token) {
+		return ""
+	}
+	return token
+}
+
+// bearerToken returns the credentials of a "Bearer" Authorization header.
+// The scheme is matched case-insensitively and surrounding whitespace,
+// including repeated spaces after the scheme, is ignored.
+func bearerToken(header string) string {
+	header = strings.TrimSpace(header)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// wellFormedToken rejects tokens containing whitespace or control
+// characters, so malformed headers never reach the DB as "no token".
+func wellFormedToken(token string) bool {
+	for _, c := range token {
+		if c <= ' ' || c == 0x7f || unicode.IsSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeCookieValue undoes percent-encoding added by some proxies and CDNs.
+// Values without a valid %XX escape are returned unchanged, so clean tokens
+// are never decoded twice.
+func decodeCookieValue(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// skipAuth reports whether r is exempt from authentication.
+func (v *Validator) skipAuth(r *http.Request) bool {
+	if v.skipFunc != nil && v.skipFunc(r) {
+		return true
+	}
+	for _, p := range v.skipPaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		} else if r.URL.Path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorized rejects a request that has no valid session. Browser
+// navigations are redirected to the login page when WithUnauthorizedRedirect
+// is set; everything else gets a 401 carrying reason.
+func (v *Validator) unauthorized(w http.ResponseWriter, r *http.Request, reason error) {
+	status := v.statusFor(rejectionReason(reason), http.StatusUnauthorized)
+	if status == http.StatusUnauthorized && v.loginURL != "" && wantsHTML(r) {
+		if u, err := url.Parse(v.loginURL); err == nil {
+			q := u.Query()
+			q.Set("next", r.URL.RequestURI())
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+	}
+	v.writeError(w, status, "unauthorized", reason)
+}
+
+// wantsHTML reports whether r looks like a browser page navigation rather
+// than an API or XHR call.
+func wantsHTML(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("X-Requested-With"), "XMLHttpRequest") {
+		return false
 	}
-	return ""
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// ValidateRequest resolves the user for r the same way Middleware does:
+// service tokens first, then Better Auth's signed cookie cache (see
+// WithCookieCacheSecret), then the session table. It returns a nil User
+// and nil error when r is not authenticated, or ErrUserBanned.
+func (v *Validator) ValidateRequest(r *http.Request) (*User, error) {
+	res, err := v.authenticateRequest(r)
+	if err == nil && errors.Is(res.reason, ErrUserBanned) {
+		err = res.reason
+	}
+	return res.user, err
+}
+
+// authResult is the outcome of authenticating a request.
+type authResult struct {
+	user    *User
+	session *Session // nil for service tokens and JWTs
+	token   string   // token as presented by the client
+	source  string   // one of the Source constants
+	reason  error    // why user is nil, if rejected
+
+	scopes    map[string]bool // API key scopes; nil for full access
+	requestID string          // with WithRequestIDHeader
+}
+
+func (v *Validator) authenticateRequest(r *http.Request) (authResult, error) {
+	ctx, end := v.startTrace(r.Context())
+	res, err := v.resolveRequest(r.WithContext(ctx))
+	res.requestID = v.requestID(r)
+	end(res, err)
+	if res.user == nil {
+		v.logFailure(res, err)
+	}
+	return res, err
+}
+
+func (v *Validator) resolveRequest(r *http.Request) (authResult, error) {
+	if user := v.serviceUser(r); user != nil {
+		return authResult{user: user, source: SourceService}, nil
+	}
+	if res, ok, err := v.resolveAPIKey(r); ok {
+		return res, err
+	}
+	// Try each candidate until one is valid; an error only surfaces if no
+	// candidate succeeds.
+	var lastErr error
+	reason := ErrNoToken
+	candidates := v.tokensFromRequest(r)
+	for _, c := range candidates {
+		kind := v.classify(c)
+		if kind == TokenSkip {
+			continue
+		}
+		reason = ErrSessionNotFound
+		switch kind {
+		case TokenJWT:
+			if v.jwks == nil {
+				continue
+			}
+			user, err := v.authenticateJWT(r.Context(), c.token)
+			if errors.Is(err, ErrUserBanned) {
+				reason = err
+			} else if err != nil {
+				lastErr = err
+			} else if user != nil {
+				return authResult{user: user, token: c.token, source: SourceJWT}, nil
+			}
+			continue
+		case TokenAPIKey:
+			user, scopes, err := v.authenticateAPIKey(r.Context(), c.token, v.now())
+			err = dbError(err)
+			if isRejection(err) || errors.Is(err, ErrUserBanned) {
+				reason = err
+			} else if err != nil {
+				lastErr = err
+			} else {
+				return authResult{user: user, token: c.token, source: SourceAPIKey, scopes: scopes}, nil
+			}
+			continue
+		}
+		if user, s := v.userFromCookieCache(r, c.token); user != nil {
+			return authResult{user: user, session: s, token: c.token, source: SourceCookieCache}, nil
+		}
+		user, s, err := v.authenticate(r.Context(), c.token)
+		if isRejection(err) || errors.Is(err, ErrUserBanned) {
+			reason = err
+			continue
+		}
+		if errors.Is(err, ErrCircuitOpen) {
+			reason = err
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if user != nil {
+			return authResult{user: user, session: s, token: c.token, source: c.source}, nil
+		}
+	}
+	res := authResult{reason: reason}
+	if len(candidates) > 0 {
+		res.source = candidates[0].source
+		res.token = candidates[0].token
+	}
+	return res, lastErr
+}
+
+// serveAuthenticated hands an authenticated request to next, re-issuing the
+// session cookie first if it was refreshed and WithRefreshCookie is set.
+// The WithUserEnricher callback runs before anything is written. During
+// SetLockdown, users outside the allowlist get 503 instead.
+func (v *Validator) serveAuthenticated(w http.ResponseWriter, r *http.Request, res authResult, next http.Handler) {
+	if v.lockedOut(res.user) {
+		v.lockdownUnavailable(w)
+		return
+	}
+	if v.useDetector != nil && res.session != nil {
+		v.useDetector.observe(res.token, v.clientIP(r), v.now())
+	}
+	if v.activity != nil && res.session != nil {
+		v.activity.touch(res.session.ID, v.now())
+	}
+	v.warnExpiry(res.user, res.session)
+	ctx := v.withAuth(r.Context(), res)
+	if v.enrichUser != nil {
+		var err error
+		if ctx, err = v.enrichUser(ctx, res.user); err != nil {
+			log.Printf("[corral] User enricher failed for %s: %v", res.user.ID, err)
+			v.writeError(w, http.StatusInternalServerError, "internal server error", nil)
+			return
+		}
+	}
+	if v.refreshCookie && res.session != nil && res.session.Refreshed {
+		http.SetCookie(w, v.sessionCookie(res.token, res.session.ExpiresAt))
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// withAuth stores the authenticated user (and token, with
+// WithPropagateToken) in ctx for the downstream handler.
+func (v *Validator) withAuth(ctx context.Context, res authResult) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, res.user)
+	if res.session != nil {
+		ctx = context.WithValue(ctx, sessionContextKey{}, res.session)
+	}
+	if v.propagateToken && res.session != nil {
+		ctx = context.WithValue(ctx, tokenContextKey{}, res.token)
+	}
+	if res.scopes != nil {
+		ctx = context.WithValue(ctx, scopesContextKey{}, res.scopes)
+	}
+	if res.requestID != "" {
+		ctx = context.WithValue(ctx, requestIDContextKey{}, res.requestID)
+	}
+	return ctx
 }
 
 // Middleware validates the session and sets the User in context.
 // Returns 401 if no valid session. Use UserFromContext to retrieve.
+// Requests matching WithSkipPaths or WithSkipFunc pass through without a
+// User.
 func (v *Validator) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := extractToken(r)
-		if token == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		if v.skipAuth(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
-		user, err := v.ValidateSession(token)
-		if err != nil || user == nil {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		res, err := v.authenticateRequest(r)
+		if errors.Is(res.reason, ErrCircuitOpen) && v.breaker.mode == BreakerFailOpen {
+			next.ServeHTTP(w, r)
 			return
 		}
-		ctx := context.WithValue(r.Context(), contextKey{}, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		if err != nil || res.user == nil {
+			v.reject(w, r, res.reason, err)
+			return
+		}
+		v.serveAuthenticated(w, r, res, next)
+	})
+}
+
+// RequireFreshSessionMiddleware is like Middleware but also returns 403 when
+// the session was created more than within ago, forcing re-authentication
+// before sensitive actions such as changing a password.
+func (v *Validator) RequireFreshSessionMiddleware(within time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := v.authenticateRequest(r)
+			if err != nil || res.user == nil {
+				v.reject(w, r, res.reason, err)
+				return
+			}
+			if res.session == nil || v.now().Sub(res.session.CreatedAt) > within {
+				v.writeError(w, http.StatusForbidden, "session not fresh", ErrSessionNotFresh)
+				return
+			}
+			v.serveAuthenticated(w, r, res, next)
+		})
+	}
+}
+
+// RequirePlanMiddleware returns 403 unless the User set by an enclosing
+// Middleware has at least the given plan (see v.RequirePlan).
+func (v *Validator) RequirePlanMiddleware(plan string) func(http.Handler) http.Handler {
+	return v.requireUser(ErrInsufficientPlan, func(u *User) bool {
+		return v.RequirePlan(u, plan)
 	})
 }
+
+// RequireRoleMiddleware returns 403 unless the User set by an enclosing
+// Middleware has the given role (see v.RequireRole).
+func (v *Validator) RequireRoleMiddleware(role string) func(http.Handler) http.Handler {
+	return v.requireUser(ErrInsufficientRole, func(u *User) bool {
+		return v.RequireRole(u, role)
+	})
+}
+
+// requireUser rejects anonymous requests (401) or those whose User fails ok
+// (403 with reason).
+func (v *Validator) requireUser(reason error, ok func(*User) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := UserFromContext(r.Context())
+			if u.IsAnonymous() {
+				v.unauthorized(w, r, ErrNoToken)
+				return
+			}
+			if !ok(u) {
+				v.forbidden(w, reason)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
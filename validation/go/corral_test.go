@@ -0,0 +1,212 @@
+package corral
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestValidator creates an empty Better Auth database and returns a
+// validator on it with opts, plus a handle for seeding rows.
+func newTestValidator(t testing.TB, opts ...Option) (*Validator, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		`CREATE TABLE user (id TEXT PRIMARY KEY, email TEXT, name TEXT, plan TEXT, role TEXT,
+			emailVerified INTEGER, createdAt TEXT, updatedAt TEXT)`,
+		`CREATE TABLE session (id TEXT PRIMARY KEY, token TEXT, userId TEXT, expiresAt TEXT,
+			createdAt TEXT, updatedAt TEXT, ipAddress TEXT, userAgent TEXT)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := newValidator(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	return v, db
+}
+
+// insertUser adds a user row with the given plan and role.
+func insertUser(t testing.TB, db *sql.DB, id, plan, role string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO user (id, email, name, plan, role, emailVerified, createdAt, updatedAt)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?)`,
+		id, id+"@example.com", id, plan, role, "2024-01-01T00:00:00.000Z", "2024-01-01T00:00:00.000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// insertSession adds a session for userID with the given token, creation
+// time and expiry.
+func insertSession(t testing.TB, db *sql.DB, token, userID string, created, expires time.Time) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO session (id, token, userId, expiresAt, createdAt, updatedAt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		"s-"+token, token, userID, formatTime(expires), formatTime(created), formatTime(created))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCookieTokenDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		decoder func(string) string
+		want    string
+	}{
+		{"plain", "abc123", nil, "abc123"},
+		{"plain base64", "abc+/123==", nil, "abc+/123=="},
+		{"percent-encoded", "abc%2B%2F123%3D%3D", nil, "abc+/123=="},
+		{"lowercase escapes", "abc%2b123%3d", nil, "abc+123="},
+		{"decoded only once", "abc%2525", nil, "abc%25"},
+		{"invalid escape kept", "abc%zz", nil, "abc%zz"},
+		{"custom decoder after percent-decoding", "ABC%3D", strings.ToLower, "abc="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.decoder != nil {
+				opts = append(opts, WithTokenDecoder(tt.decoder))
+			}
+			v, _ := newTestValidator(t, opts...)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Cookie", CookieName+"="+tt.value)
+			tokens := v.extractTokens(r)
+			if len(tokens) != 1 || tokens[0].token != tt.want {
+				t.Fatalf("extractTokens = %+v, want token %q", tokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerTokenExtraction(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"valid", "Bearer abc123", "abc123"},
+		{"lowercase scheme", "bearer abc123", "abc123"},
+		{"uppercase scheme", "BEARER abc123", "abc123"},
+		{"double space", "Bearer  abc123", "abc123"},
+		{"trailing CR", "Bearer abc123\r", "abc123"},
+		{"surrounding space", "  Bearer abc123  ", "abc123"},
+		{"empty", "", ""},
+		{"empty scheme", " abc123", ""},
+		{"scheme only", "Bearer", ""},
+		{"scheme and space", "Bearer ", ""},
+		{"no space", "Bearerabc123", ""},
+		{"other scheme", "Basic abc123", ""},
+		{"embedded space", "Bearer abc 123", ""},
+		{"embedded tab", "Bearer abc\t123", ""},
+		{"embedded control", "Bearer abc\x00123", ""},
+		{"embedded newline", "Bearer abc\n123", ""},
+	}
+	v, _ := newTestValidator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header["Authorization"] = []string{tt.header}
+			tokens := v.extractTokens(r)
+			var got string
+			if len(tokens) > 0 {
+				got = tokens[0].token
+			}
+			if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanNormalization(t *testing.T) {
+	tests := []struct {
+		stored string
+		plan   string // as read back
+		pro    bool   // meets "pro"
+	}{
+		{"pro", "pro", true},
+		{"Pro", "pro", true},
+		{" PRO ", "pro", true},
+		{"\tTeam\n", "team", true},
+		{"FREE", "free", false},
+		{"  ", "free", false},
+		{"", "free", false},
+	}
+	v, db := newTestValidator(t)
+	for i, tt := range tests {
+		id := fmt.Sprintf("u%d", i)
+		insertUser(t, db, id, tt.stored, "user")
+		u, err := v.GetUserByID(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Plan != tt.plan {
+			t.Errorf("plan %q read as %q, want %q", tt.stored, u.Plan, tt.plan)
+		}
+		if got := RequirePlan(u, "pro"); got != tt.pro {
+			t.Errorf("RequirePlan(%q, pro) = %v, want %v", tt.stored, got, tt.pro)
+		}
+		if got := v.RequirePlan(u, " Pro "); got != tt.pro {
+			t.Errorf("v.RequirePlan(%q, \" Pro \") = %v, want %v", tt.stored, got, tt.pro)
+		}
+	}
+
+	// Users built by hand are compared case-insensitively too.
+	if !RequirePlan(&User{Plan: " Enterprise"}, "TEAM") {
+		t.Error("RequirePlan(Enterprise, TEAM) = false, want true")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	tests := []struct {
+		stored  string
+		role    string
+		roles   []string
+		admin   bool // RequireRole "admin"
+		support bool // RequireRole "support", ranked below admin
+	}{
+		{"user", "user", []string{"user"}, false, false},
+		{"admin", "admin", []string{"admin"}, true, true},
+		{"", "user", []string{"user"}, false, false},
+		{"admin,user", "admin", []string{"admin", "user"}, true, true},
+		{"user,admin", "user", []string{"user", "admin"}, true, true},
+		{" user , Support ,", "user", []string{"user", "support"}, false, true},
+		{",,", "user", []string{"user"}, false, false},
+	}
+	v, db := newTestValidator(t)
+	v.SetRoleHierarchy(map[string]int{"user": 0, "support": 1, "admin": 2})
+	for i, tt := range tests {
+		id := fmt.Sprintf("u%d", i)
+		insertUser(t, db, id, "free", tt.stored)
+		u, err := v.GetUserByID(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Role != tt.role || !slices.Equal(u.Roles, tt.roles) {
+			t.Errorf("role %q read as %q %q, want %q %q", tt.stored, u.Role, u.Roles, tt.role, tt.roles)
+		}
+		if got := v.RequireRole(u, "admin"); got != tt.admin {
+			t.Errorf("RequireRole(%q, admin) = %v, want %v", tt.stored, got, tt.admin)
+		}
+		if got := v.RequireRole(u, "support"); got != tt.support {
+			t.Errorf("RequireRole(%q, support) = %v, want %v", tt.stored, got, tt.support)
+		}
+	}
+}
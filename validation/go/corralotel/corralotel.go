@@ -0,0 +1,72 @@
+// Package corralotel records corral session validations as OpenTelemetry
+// spans. It lives in its own package so the core validator does not depend
+// on OpenTelemetry.
+//
+// Usage:
+//
+//	v := corral.NewValidator("/data/auth.db", corral.WithTracer(corralotel.NewTracer(nil)))
+package corralotel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	corral "github.com/llamafarm/corral-validate/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/llamafarm/corral-validate/go"
+	spanName            = "corral.ValidateSession"
+)
+
+// NewTracer returns a corral.Tracer that starts a corral.ValidateSession
+// child span whenever the validation context already carries a span. The
+// span records the result, the token source and a hash of the user ID, and
+// any error. A nil tp uses the global TracerProvider.
+func NewTracer(tp trace.TracerProvider) corral.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tracer{t: tp.Tracer(instrumentationName)}
+}
+
+type tracer struct {
+	t trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context) (context.Context, func(corral.ValidationEvent)) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, func(corral.ValidationEvent) {}
+	}
+	ctx, span := t.t.Start(ctx, spanName)
+	return ctx, func(e corral.ValidationEvent) {
+		attrs := []attribute.KeyValue{
+			attribute.String("corral.result", e.Result),
+			attribute.String("corral.token_source", e.Source),
+		}
+		if e.UserID != "" {
+			attrs = append(attrs, attribute.String("corral.user_id_hash", hashID(e.UserID)))
+		}
+		if e.RequestID != "" {
+			attrs = append(attrs, attribute.String("corral.request_id", e.RequestID))
+		}
+		span.SetAttributes(attrs...)
+		if e.Err != nil {
+			span.RecordError(e.Err)
+			span.SetStatus(codes.Error, e.Err.Error())
+		}
+		span.End()
+	}
+}
+
+// hashID keeps raw user IDs out of trace backends while still allowing
+// spans for the same user to be correlated.
+func hashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
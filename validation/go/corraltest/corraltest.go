@@ -0,0 +1,87 @@
+// Package corraltest provides an in-memory stand-in for the Node auth
+// server, so code using AuthProxyHandler or AuthServerHealthy can be tested
+// without Node.
+//
+// Usage:
+//
+//	fake := corraltest.NewFakeAuthServer(handler)
+//	defer fake.Close()
+//	v := corral.NewValidator(dbPath, corral.WithExistingAuthServer(fake.URL))
+package corraltest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+)
+
+// FakeAuthServer is an httptest.Server that answers the auth server's
+// health check at /api/auth/ok and passes every other request to a
+// caller-supplied handler. The validator probes health every few seconds,
+// so SetHealthy and SetCrashed take effect on AuthServerHealthy after the
+// next probe but on proxied requests at once.
+type FakeAuthServer struct {
+	// URL is the base URL to pass to corral.WithExistingAuthServer.
+	URL string
+
+	srv       *httptest.Server
+	unhealthy atomic.Bool
+	crashed   atomic.Bool
+}
+
+// NewFakeAuthServer starts a healthy fake auth server. A nil handler
+// answers 404 for everything but the health check.
+func NewFakeAuthServer(handler http.Handler) *FakeAuthServer {
+	if handler == nil {
+		handler = http.NotFoundHandler()
+	}
+	f := &FakeAuthServer{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.crashed.Load() {
+			hangUp(w)
+			return
+		}
+		if r.URL.Path == "/api/auth/ok" {
+			if f.unhealthy.Load() {
+				http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	f.URL = f.srv.URL
+	return f
+}
+
+// SetHealthy makes the health check answer 200 (true) or 503 (false).
+// Other requests are still served.
+func (f *FakeAuthServer) SetHealthy(healthy bool) {
+	f.unhealthy.Store(!healthy)
+}
+
+// SetCrashed simulates a dead process: while true, every connection is
+// closed without a response.
+func (f *FakeAuthServer) SetCrashed(crashed bool) {
+	f.crashed.Store(crashed)
+}
+
+// Close shuts the server down.
+func (f *FakeAuthServer) Close() {
+	f.srv.Close()
+}
+
+// hangUp drops the connection without writing a response.
+func hangUp(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -0,0 +1,62 @@
+package corraltest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corral "github.com/llamafarm/corral-validate/go"
+	"github.com/llamafarm/corral-validate/go/corraltest"
+)
+
+func TestFakeAuthServerHealth(t *testing.T) {
+	fake := corraltest.NewFakeAuthServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer fake.Close()
+	v, err := corral.NewValidatorE(filepath.Join(t.TempDir(), "auth.db"), corral.WithExistingAuthServer(fake.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	health := v.HealthHandler(corral.HealthAuthServer)
+	proxy := v.AuthProxyHandler()
+
+	// waitHealth waits for the next probes to report healthy, then checks
+	// the health endpoint and a proxied request agree.
+	waitHealth := func(step string, healthy bool, proxied int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); v.AuthServerHealthy() != healthy; {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: AuthServerHealthy = %v, want %v", step, !healthy, healthy)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		want := http.StatusOK
+		if !healthy {
+			want = http.StatusServiceUnavailable
+		}
+		rec := httptest.NewRecorder()
+		health.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		if rec.Code != want {
+			t.Fatalf("%s: health status = %d, want %d", step, rec.Code, want)
+		}
+		rec = httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/api/auth/session", nil))
+		if rec.Code != proxied {
+			t.Fatalf("%s: proxied status = %d, want %d", step, rec.Code, proxied)
+		}
+	}
+
+	waitHealth("started", true, http.StatusNoContent)
+	fake.SetHealthy(false)
+	waitHealth("unhealthy", false, http.StatusServiceUnavailable)
+	fake.SetHealthy(true)
+	waitHealth("recovered", true, http.StatusNoContent)
+	fake.SetCrashed(true)
+	waitHealth("crashed", false, http.StatusServiceUnavailable)
+	fake.SetCrashed(false)
+	waitHealth("restarted", true, http.StatusNoContent)
+}
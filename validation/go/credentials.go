@@ -0,0 +1,78 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// WithInvalidateOnPasswordChange rejects sessions created before the
+// timestamp in the given user column (e.g. "passwordChangedAt"), so that
+// changing credentials logs out every existing session. A NULL value
+// disables the check for that user. If the column does not exist the check
+// is logged once and turned off.
+func WithInvalidateOnPasswordChange(column string) Option {
+	return func(v *Validator) {
+		v.pwChangedCol = column
+	}
+}
+
+// predatesCredentialChange reports whether s was created before its user's
+// credentials last changed.
+func (v *Validator) predatesCredentialChange(ctx context.Context, db Querier, s *Session) (bool, error) {
+	if v.pwChangedCol == "" || v.pwColMissing.Load() {
+		return false, nil
+	}
+	// Qualified so SQLite reports a missing column instead of reading the
+	// quoted name as a string literal.
+	query := fmt.Sprintf(`SELECT %s.%s FROM %s WHERE %s = ?`,
+		v.sq.UserTable, quoteIdent(v.pwChangedCol), v.sq.UserTable, v.sq.UserPKCol)
+	var changedAt sql.NullString
+	err := db.QueryRowContext(ctx, query, s.UserID).Scan(&changedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		if isMissingColumn(err) {
+			v.disablePasswordCheck()
+			return false, nil
+		}
+		return false, err
+	}
+	return createdBefore(s, changedAt)
+}
+
+// createdBefore reports whether s was created before changedAt, a value of
+// the WithInvalidateOnPasswordChange column. NULL or empty never is.
+func createdBefore(s *Session, changedAt sql.NullString) (bool, error) {
+	if !changedAt.Valid || changedAt.String == "" {
+		return false, nil
+	}
+	t, err := parseTime(changedAt.String)
+	if err != nil {
+		return false, err
+	}
+	return s.CreatedAt.Before(t), nil
+}
+
+// disablePasswordCheck records that the WithInvalidateOnPasswordChange
+// column does not exist.
+func (v *Validator) disablePasswordCheck() {
+	if v.pwColMissing.CompareAndSwap(false, true) {
+		log.Printf("[corral] Column %q not found on user table; password-change invalidation disabled", v.pwChangedCol)
+	}
+}
+
+// isMissingTableColumn reports whether err is SQLite rejecting col,
+// selected qualified by its table.
+func isMissingTableColumn(err error, col string) bool {
+	msg := err.Error()
+	return isMissingColumn(err) && (strings.Contains(msg, "."+col+" ") || strings.HasSuffix(msg, "."+col))
+}
+
+// isMissingColumn reports whether err is SQLite rejecting an unknown column.
+func isMissingColumn(err error) bool {
+	return strings.Contains(err.Error(), "no such column")
+}
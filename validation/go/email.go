@@ -0,0 +1,67 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// WithEmailCanonicalizer sets how GetUserByEmail normalizes its input before
+// the exact-match lookup. It must match how emails were stored at sign-up;
+// the default, strings.ToLower, matches Better Auth. CanonicalEmail also
+// folds Gmail aliases.
+func WithEmailCanonicalizer(fn func(string) string) Option {
+	return func(v *Validator) {
+		v.canonicalEmail = fn
+	}
+}
+
+// CanonicalEmail lowercases email and drops any "+tag" from the local part,
+// and for gmail.com and googlemail.com addresses also removes dots and
+// normalizes the domain to gmail.com. For use with WithEmailCanonicalizer
+// when sign-up stored emails the same way.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if i := strings.IndexByte(local, '+'); i >= 0 {
+		local = local[:i]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// GetUserByEmail returns the user whose email matches email after
+// canonicalization (see WithEmailCanonicalizer), or nil if there is none.
+func (v *Validator) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	db, err := v.openUsers()
+	if err != nil {
+		return nil, err
+	}
+	canonical := strings.ToLower
+	if v.canonicalEmail != nil {
+		canonical = v.canonicalEmail
+	}
+	query := fmt.Sprintf(`%s WHERE %s = ?`, v.queries.selectUsers, v.sq.EmailCol)
+	u, err := scanUser(db.QueryRowContext(ctx, query, canonical(email)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := v.loadUserColumns(ctx, db, u); err != nil {
+		return nil, err
+	}
+	if v.postProcessUser != nil {
+		v.postProcessUser(u)
+	}
+	return u, nil
+}
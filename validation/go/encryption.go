@@ -0,0 +1,65 @@
+package corral
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// defaultDriver is modernc.org/sqlite, which cannot open encrypted files.
+const defaultDriver = "sqlite"
+
+// ErrEncryptionUnsupported is returned by NewValidatorE when WithSQLiteKey
+// is used without a SQLCipher-capable driver.
+var ErrEncryptionUnsupported = errors.New("corral: WithSQLiteKey requires a SQLCipher driver (see WithSQLDriver)")
+
+// WithSQLDriver opens the database with the named database/sql driver
+// instead of modernc.org/sqlite. The driver must be registered, usually by
+// a blank import in package main.
+func WithSQLDriver(name string) Option {
+	return func(v *Validator) {
+		v.driverName = name
+	}
+}
+
+// WithSQLiteKey opens a SQLCipher-encrypted database with key, passed as
+// the _pragma_key DSN parameter understood by SQLCipher drivers such as
+// github.com/mutecomm/go-sqlcipher. It must be combined with WithSQLDriver.
+func WithSQLiteKey(key string) Option {
+	return func(v *Validator) {
+		v.sqliteKey = key
+	}
+}
+
+// checkDriver rejects driver settings that cannot work.
+func (v *Validator) checkDriver() error {
+	if !slices.Contains(sql.Drivers(), v.driverName) {
+		return fmt.Errorf("corral: SQL driver %q is not registered", v.driverName)
+	}
+	if v.sqliteKey != "" && v.driverName == defaultDriver {
+		return ErrEncryptionUnsupported
+	}
+	return nil
+}
+
+// dsn returns the data source name of the main database for the
+// configured driver.
+func (v *Validator) dsn() string {
+	return v.dsnFor(v.dbPath)
+}
+
+// dsnFor returns the data source name for the database at path, adding the
+// WithSQLiteKey key.
+func (v *Validator) dsnFor(path string) string {
+	if v.sqliteKey == "" {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma_key=" + url.QueryEscape(v.sqliteKey)
+}
@@ -0,0 +1,121 @@
+package corral
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Rejection reasons. The middlewares report them to clients as the "code"
+// field when WithJSONErrors is set; ValidateSession and friends still
+// return a nil User and nil error for rejected tokens.
+var (
+	ErrNoToken          = errors.New("corral: no session token")
+	ErrSessionNotFound  = errors.New("corral: session not found")
+	ErrSessionExpired   = errors.New("corral: session expired")
+	ErrSessionNotFresh  = errors.New("corral: session not fresh")
+	ErrInsufficientPlan = errors.New("corral: insufficient plan")
+	ErrInsufficientRole = errors.New("corral: insufficient role")
+	ErrNonceMissing     = errors.New("corral: missing request nonce")
+	ErrNonceReplayed    = errors.New("corral: request nonce replayed")
+	ErrEmailNotVerified = errors.New("corral: email not verified")
+)
+
+// WithJSONErrors makes the middlewares answer rejections with a JSON body
+// such as {"error":"unauthorized","code":"session_expired"} instead of
+// plain text.
+func WithJSONErrors(enabled bool) Option {
+	return func(v *Validator) {
+		v.jsonErrors = enabled
+	}
+}
+
+// errorCode returns the machine-readable code for a rejection reason, or ""
+// if err is not one.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return "no_token"
+	case errors.Is(err, ErrSessionNotFound):
+		return "session_not_found"
+	case errors.Is(err, ErrSessionExpired):
+		return "session_expired"
+	case errors.Is(err, ErrSessionNotFresh):
+		return "session_not_fresh"
+	case errors.Is(err, ErrInsufficientPlan):
+		return "insufficient_plan"
+	case errors.Is(err, ErrInsufficientRole):
+		return "insufficient_role"
+	case errors.Is(err, ErrEmailNotVerified):
+		return "email_not_verified"
+	case errors.Is(err, ErrInsufficientScope):
+		return "insufficient_scope"
+	case errors.Is(err, ErrUserBanned):
+		return "user_banned"
+	case errors.Is(err, ErrNonceMissing):
+		return "nonce_missing"
+	case errors.Is(err, ErrNonceReplayed):
+		return "nonce_replayed"
+	case errors.Is(err, ErrLockdown):
+		return "lockdown"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	}
+	return ""
+}
+
+// isRejection reports whether err means the token is simply not valid,
+// as opposed to a failure to validate or a banned user.
+func isRejection(err error) bool {
+	return errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired)
+}
+
+// writeError answers with status, using text as the plain-text body or the
+// JSON "error" field, and reason as the JSON "code".
+func (v *Validator) writeError(w http.ResponseWriter, status int, text string, reason error) {
+	if !v.jsonErrors {
+		http.Error(w, text, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  string `json:"code,omitempty"`
+	}{text, errorCode(reason)})
+}
+
+// reject answers a request that failed authentication: 403 for banned
+// users, 500 when the session couldn't be checked, otherwise unauthorized.
+// err is the failure to validate, if any.
+func (v *Validator) reject(w http.ResponseWriter, r *http.Request, reason, err error) {
+	if errors.Is(reason, ErrUserBanned) {
+		v.forbidden(w, reason)
+		return
+	}
+	if errors.Is(reason, ErrCircuitOpen) {
+		v.circuitOpen(w)
+		return
+	}
+	if err != nil {
+		// A database outage says nothing about the session, so it must not
+		// look like a logout to the client.
+		status := v.statusFor(ReasonDBError, http.StatusInternalServerError)
+		if status >= http.StatusInternalServerError {
+			v.writeError(w, status, "internal server error", reason)
+			return
+		}
+		if status != http.StatusUnauthorized {
+			v.writeError(w, status, "unauthorized", reason)
+			return
+		}
+	}
+	v.unauthorized(w, r, reason)
+}
+
+// forbidden rejects an authenticated request that fails an authorization
+// check.
+func (v *Validator) forbidden(w http.ResponseWriter, reason error) {
+	v.writeError(w, v.statusFor(rejectionReason(reason), http.StatusForbidden), "forbidden", reason)
+}
@@ -0,0 +1,48 @@
+package corral
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WithExistingAuthServer points AuthProxyHandler and AuthServerHealthy at an
+// auth server the validator doesn't manage, such as a sidecar or a
+// corraltest.FakeAuthServer, at baseURL (e.g. "http://127.0.0.1:3456").
+// Nothing is spawned, WithAuthServer is ignored, and Close leaves the server
+// running; its health is probed as for a managed one.
+func WithExistingAuthServer(baseURL string) Option {
+	return func(v *Validator) {
+		v.authURL = baseURL
+	}
+}
+
+// attachAuthServer registers the WithExistingAuthServer URL in place of a
+// spawned process and starts probing it.
+func (v *Validator) attachAuthServer() error {
+	u, err := url.Parse(v.authURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("corral: invalid auth server URL %q", v.authURL)
+	}
+
+	v.authMu.Lock()
+	defer v.authMu.Unlock()
+
+	// exited is never closed: the process isn't ours to reap.
+	p := &authProc{port: u.Port(), base: strings.TrimSuffix(v.authURL, "/"), exited: make(chan struct{})}
+	procs := []*authProc{p}
+	v.authProcs.Store(&procs)
+
+	client := &http.Client{Timeout: time.Second}
+	p.healthy.Store(probeAuthServer(client, p.healthURL()))
+	if !p.healthy.Load() {
+		log.Printf("[corral-auth] Auth server at %s is not healthy yet", p.base)
+	}
+	v.goBackground(func(stop <-chan struct{}) {
+		probeAuthServerLoop(stop, p, client)
+	})
+	return nil
+}
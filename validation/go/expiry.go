@@ -0,0 +1,36 @@
+package corral
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// WithExpiryWarning calls cb when a valid session has less than threshold
+// left, e.g. to log or to prompt the user to sign in again before it runs
+// out. It fires for sessions validated by the middlewares,
+// ValidateSession and ValidateSessionFull, after any sliding refresh. cb
+// runs on its own goroutine with a copy of the user, so it never delays
+// the request. To show the remaining time in a response, read
+// SessionFromContext in the handler instead.
+func WithExpiryWarning(threshold time.Duration, cb func(user *User, remaining time.Duration)) Option {
+	return func(v *Validator) {
+		v.expiryWarn = threshold
+		v.onExpiring = cb
+	}
+}
+
+// warnExpiry fires the WithExpiryWarning callback if s is close to expiry.
+func (v *Validator) warnExpiry(u *User, s *Session) {
+	if v.onExpiring == nil || u == nil || s == nil {
+		return
+	}
+	remaining := s.ExpiresAt.Sub(v.now())
+	if remaining <= 0 || remaining >= v.expiryWarn {
+		return
+	}
+	copied := *u
+	copied.Extra = maps.Clone(u.Extra)
+	copied.Roles = slices.Clone(u.Roles)
+	go v.onExpiring(&copied, remaining)
+}
@@ -0,0 +1,48 @@
+package corral
+
+import (
+	"log"
+	"math/rand/v2"
+)
+
+// WithFailureLogSampling logs the given fraction (0 to 1) of failed request
+// validations with their reason and a redacted token prefix. Successful
+// validations are never logged.
+func WithFailureLogSampling(rate float64) Option {
+	return func(v *Validator) {
+		v.failureLogRate = rate
+	}
+}
+
+// logFailure logs a sample of failed validations.
+func (v *Validator) logFailure(res authResult, err error) {
+	if v.failureLogRate <= 0 || rand.Float64() >= v.failureLogRate {
+		return
+	}
+	reason := res.reason
+	if err != nil {
+		reason = err
+	}
+	source := res.source
+	if source == "" {
+		source = "none"
+	}
+	if res.requestID != "" {
+		log.Printf("[corral] Validation failed (request %s, source %s, token %s): %v",
+			res.requestID, source, redactToken(res.token), reason)
+		return
+	}
+	log.Printf("[corral] Validation failed (source %s, token %s): %v", source, redactToken(res.token), reason)
+}
+
+// redactToken keeps just enough of token to correlate log lines.
+func redactToken(token string) string {
+	const keep = 6
+	if token == "" {
+		return "-"
+	}
+	if len(token) <= keep {
+		return "…"
+	}
+	return token[:keep] + "…"
+}
@@ -0,0 +1,33 @@
+package corral
+
+import "maps"
+
+// WithFeatureMatrix maps feature names to the minimum plan that unlocks
+// them, for UserCan. The map is copied.
+func WithFeatureMatrix(features map[string]string) Option {
+	return func(v *Validator) {
+		v.features = maps.Clone(features)
+	}
+}
+
+// WithAllowUnknownFeatures makes UserCan allow features missing from the
+// WithFeatureMatrix map instead of denying them.
+func WithAllowUnknownFeatures(allow bool) Option {
+	return func(v *Validator) {
+		v.allowUnknown = allow
+	}
+}
+
+// UserCan reports whether user's plan unlocks feature, comparing against
+// the feature's minimum plan from WithFeatureMatrix with v.RequirePlan.
+// A nil user is always denied.
+func (v *Validator) UserCan(user *User, feature string) bool {
+	if user == nil {
+		return false
+	}
+	plan, ok := v.features[feature]
+	if !ok {
+		return v.allowUnknown
+	}
+	return v.RequirePlan(user, plan)
+}
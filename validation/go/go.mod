@@ -2,4 +2,9 @@ module github.com/llamafarm/corral-validate/go
 
 go 1.22
 
-require modernc.org/sqlite v1.34.5
+require (
+	go.opentelemetry.io/otel v1.32.0
+	go.opentelemetry.io/otel/trace v1.32.0
+	golang.org/x/time v0.8.0
+	modernc.org/sqlite v1.34.5
+)
@@ -0,0 +1,83 @@
+package corral
+
+import (
+	"log"
+	"net/http"
+)
+
+// UserHeaders names the request headers HeaderInjectionMiddleware sets for
+// downstream services. Empty fields are neither set nor stripped.
+type UserHeaders struct {
+	ID    string
+	Email string
+	Plan  string
+	Role  string
+}
+
+// DefaultUserHeaders are the header names used unless WithUserHeaders is set.
+var DefaultUserHeaders = UserHeaders{
+	ID:    "X-User-Id",
+	Email: "X-User-Email",
+	Plan:  "X-User-Plan",
+	Role:  "X-User-Role",
+}
+
+// WithUserHeaders overrides the header names used by
+// HeaderInjectionMiddleware.
+func WithUserHeaders(h UserHeaders) Option {
+	return func(v *Validator) {
+		v.userHeaders = &h
+	}
+}
+
+// HeaderInjectionMiddleware is Middleware for auth gateways: it strips any
+// client-supplied user headers (see UserHeaders), validates the session, and
+// sets the headers from the authenticated user before calling next, which
+// typically proxies to a backend that trusts them.
+func (v *Validator) HeaderInjectionMiddleware(next http.Handler) http.Handler {
+	h := DefaultUserHeaders
+	if v.userHeaders != nil {
+		h = *v.userHeaders
+	}
+	set := func(r *http.Request, name, value string) {
+		if name != "" {
+			r.Header.Set(name, value)
+		}
+	}
+	var jwtHeader string
+	if v.jwtSigner != nil {
+		jwtHeader = v.downstreamJWT.Header
+	}
+	inject := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u := UserFromContext(r.Context()); u != nil {
+			set(r, h.ID, u.ID)
+			set(r, h.Email, u.Email)
+			set(r, h.Plan, u.Plan)
+			set(r, h.Role, u.Role)
+			if jwtHeader != "" {
+				jwt, err := v.MintDownstreamJWT(u)
+				if err != nil {
+					log.Printf("[corral] Failed to mint downstream JWT for %s: %v", u.ID, err)
+					v.writeError(w, http.StatusInternalServerError, "internal server error", nil)
+					return
+				}
+				set(r, jwtHeader, v.downstreamJWTValue(jwt))
+			}
+		}
+		next.ServeHTTP(w, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		for _, name := range []string{h.ID, h.Email, h.Plan, h.Role} {
+			if name != "" {
+				r.Header.Del(name)
+			}
+		}
+		// Authorization may carry the session token, so it is only
+		// replaced once the request is authenticated.
+		if jwtHeader != "" && http.CanonicalHeaderKey(jwtHeader) != "Authorization" {
+			r.Header.Del(jwtHeader)
+		}
+		inject.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,102 @@
+package corral
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthCheck is one check run by HealthHandler.
+type HealthCheck int
+
+const (
+	// HealthDB pings the database (and the WithUserDB one, if set).
+	HealthDB HealthCheck = iota
+	// HealthAuthServer requires a healthy auth server when one is managed
+	// or attached with WithExistingAuthServer; it passes otherwise.
+	HealthAuthServer
+	// HealthSchema runs VerifySchema without PRAGMA quick_check.
+	HealthSchema
+	// HealthBreaker fails while the WithCircuitBreaker breaker is open.
+	HealthBreaker
+)
+
+// String returns the name reported in HealthHandler's JSON body.
+func (c HealthCheck) String() string {
+	switch c {
+	case HealthDB:
+		return "db"
+	case HealthAuthServer:
+		return "auth_server"
+	case HealthSchema:
+		return "schema"
+	case HealthBreaker:
+		return "circuit_breaker"
+	}
+	return "unknown"
+}
+
+// healthTimeout bounds all checks of one HealthHandler request.
+const healthTimeout = 2 * time.Second
+
+// HealthHandler returns a readiness probe handler that runs checks
+// (HealthDB and HealthAuthServer if none are given) and answers 200 with
+// {"status":"ok"} if all pass, or 503 with {"status":"unavailable",
+// "failed":[...]} naming the checks that failed.
+func (v *Validator) HealthHandler(checks ...HealthCheck) http.Handler {
+	if len(checks) == 0 {
+		checks = []HealthCheck{HealthDB, HealthAuthServer}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+
+		body := struct {
+			Status string   `json:"status"`
+			Failed []string `json:"failed,omitempty"`
+		}{Status: "ok"}
+		for _, c := range checks {
+			if !v.healthy(ctx, c) {
+				body.Failed = append(body.Failed, c.String())
+			}
+		}
+		status := http.StatusOK
+		if len(body.Failed) > 0 {
+			body.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+// healthy runs one health check.
+func (v *Validator) healthy(ctx context.Context, c HealthCheck) bool {
+	switch c {
+	case HealthDB:
+		db, err := v.querier()
+		if err != nil || ping(ctx, db) != nil {
+			return false
+		}
+		users, err := v.openUsers()
+		return err == nil && (users == db || ping(ctx, users) == nil)
+	case HealthAuthServer:
+		// A skipped spawn (no Node, no script) leaves nothing to check.
+		if v.authURL == "" && len(v.authServers()) == 0 {
+			return true
+		}
+		return v.AuthServerHealthy()
+	case HealthSchema:
+		return v.VerifySchema(ctx, false) == nil
+	case HealthBreaker:
+		if v.breaker == nil {
+			return true
+		}
+		state, _ := v.breaker.snapshot()
+		return state != BreakerOpen
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package corral
+
+// SetPlanHierarchy replaces the plan levels used by v.RequirePlan and
+// RequirePlanMiddleware; a higher level satisfies every lower one. It is
+// safe to call while requests are being served, e.g. from a config-reload
+// goroutine. Plans missing from the map rank lowest. A nil map restores the
+// default free < pro < team < enterprise ordering.
+func (v *Validator) SetPlanHierarchy(levels map[string]int) {
+	v.planHierarchy.Store(normalizeLevels(levels))
+}
+
+// SetRoleHierarchy gives roles levels, so that v.RequireRole and
+// RequireRoleMiddleware accept any role ranked at or above the required
+// one. Roles missing from the map, or every role when no hierarchy is set,
+// only match themselves. It is safe to call concurrently with requests.
+func (v *Validator) SetRoleHierarchy(levels map[string]int) {
+	v.roleHierarchy.Store(normalizeLevels(levels))
+}
+
+// RequirePlan is like the package-level RequirePlan but uses the hierarchy
+// set by SetPlanHierarchy.
+func (v *Validator) RequirePlan(user *User, plan string) bool {
+	levels := planLevels
+	if p := v.planHierarchy.Load(); p != nil {
+		levels = *p
+	}
+	return levels[normalizeLevel(user.Plan)] >= levels[normalizeLevel(plan)]
+}
+
+// RequireRole reports whether any of user's roles is role or, with
+// SetRoleHierarchy, ranked at or above it. Roles compare case-insensitively.
+func (v *Validator) RequireRole(user *User, role string) bool {
+	want := normalizeLevel(role)
+	p := v.roleHierarchy.Load()
+	for _, have := range userRoles(user) {
+		if have == want {
+			return true
+		}
+		if p == nil {
+			continue
+		}
+		haveLevel, ok1 := (*p)[have]
+		wantLevel, ok2 := (*p)[want]
+		if ok1 && ok2 && haveLevel >= wantLevel {
+			return true
+		}
+	}
+	return false
+}
+
+// userRoles returns user's roles, parsing Role for users that weren't
+// loaded from the database (e.g. WithServiceToken users).
+func userRoles(user *User) []string {
+	if len(user.Roles) > 0 {
+		return user.Roles
+	}
+	return parseRoles(user.Role)
+}
+
+// normalizeLevels copies levels with normalized keys, so later changes to
+// the caller's map have no effect.
+func normalizeLevels(levels map[string]int) *map[string]int {
+	if levels == nil {
+		return nil
+	}
+	m := make(map[string]int, len(levels))
+	for name, level := range levels {
+		m[normalizeLevel(name)] = level
+	}
+	return &m
+}
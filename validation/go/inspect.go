@@ -0,0 +1,66 @@
+package corral
+
+import (
+	"context"
+	"time"
+)
+
+// TokenInfo is everything the validator knows about a token, for debugging
+// and admin tooling. Nothing is redacted.
+type TokenInfo struct {
+	Valid  bool
+	Source string // "service", "session", or "" if the token is unknown
+
+	SessionID string
+	UserID    string
+	Email     string
+	Plan      string
+	Role      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Expired   bool
+}
+
+// Describe explains what token resolves to, reading straight from the
+// database (bypassing caches and never refreshing the session). It is an
+// admin operation, not part of the request path: invalid tokens yield a
+// TokenInfo with Valid false rather than an error.
+func (v *Validator) Describe(ctx context.Context, token string) (*TokenInfo, error) {
+	info := &TokenInfo{}
+	if u := v.matchServiceToken(token); u != nil {
+		info.Valid = true
+		info.Source = "service"
+		info.UserID, info.Email, info.Plan, info.Role = u.ID, u.Email, u.Plan, u.Role
+		return info, nil
+	}
+
+	db, err := v.querier()
+	if err != nil {
+		return nil, err
+	}
+	now := v.now()
+	s, err := v.lookupSession(ctx, db, token, now)
+	if err != nil || s == nil {
+		return info, err
+	}
+	info.Source = "session"
+	info.SessionID = s.ID
+	info.UserID = s.UserID
+	info.CreatedAt = s.CreatedAt
+	info.ExpiresAt = s.ExpiresAt
+	info.Expired = s.ExpiresAt.Before(now)
+
+	users, err := v.usersQueryer(db)
+	if err != nil {
+		return nil, err
+	}
+	u, err := v.getUserByID(ctx, users, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil {
+		info.Email, info.Plan, info.Role = u.Email, u.Plan, u.Role
+	}
+	info.Valid = u != nil && !info.Expired
+	return info, nil
+}
@@ -0,0 +1,107 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrDatabaseCorrupt wraps SQLite errors reporting a damaged database file,
+// so callers can tell them apart from invalid tokens and transient
+// SQLITE_BUSY errors.
+var ErrDatabaseCorrupt = errors.New("corral: database corrupt")
+
+// SQLite primary result codes for a damaged file.
+const (
+	sqliteCorrupt = 11
+	sqliteNotADB  = 26
+)
+
+// dbError wraps err with ErrDatabaseCorrupt if it reports corruption.
+func dbError(err error) error {
+	if err == nil || !isCorrupt(err) {
+		return err
+	}
+	log.Printf("[corral] Database corruption detected: %v", err)
+	return fmt.Errorf("%w: %w", ErrDatabaseCorrupt, err)
+}
+
+func isCorrupt(err error) bool {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		// Extended codes keep the primary code in the low byte.
+		switch coded.Code() & 0xff {
+		case sqliteCorrupt, sqliteNotADB:
+			return true
+		}
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database disk image is malformed") ||
+		strings.Contains(msg, "file is not a database")
+}
+
+// VerifySchema checks that the session and user tables have every column
+// the validator reads, and with quickCheck also runs PRAGMA quick_check.
+// It is meant for startup and health checks; corruption is reported as
+// ErrDatabaseCorrupt.
+func (v *Validator) VerifySchema(ctx context.Context, quickCheck bool) error {
+	db, err := v.querier()
+	if err != nil {
+		return err
+	}
+	users, err := v.openUsers()
+	if err != nil {
+		return err
+	}
+	sq := v.sq
+	want := map[string][]string{
+		sq.SessionTable: {sq.SessionIDCol, sq.TokenCol, sq.UserIDCol, sq.ExpiresCol, sq.SessionCreatedCol},
+		sq.UserTable:    {sq.UserPKCol, sq.EmailCol, sq.NameCol, sq.PlanCol, sq.RoleCol, sq.EmailVerifiedCol, sq.UserCreatedCol},
+	}
+	for table, cols := range want {
+		tdb := db
+		if table == sq.UserTable {
+			tdb = users
+		}
+		rows, err := tdb.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, unquoteIdent(table))
+		if err != nil {
+			return dbError(err)
+		}
+		have := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return dbError(err)
+			}
+			have[quoteIdent(name)] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return dbError(err)
+		}
+		if len(have) == 0 {
+			return fmt.Errorf("corral: table %s not found", table)
+		}
+		for _, col := range cols {
+			if !have[col] {
+				return fmt.Errorf("corral: table %s has no column %s", table, col)
+			}
+		}
+	}
+
+	if quickCheck {
+		var result string
+		if err := db.QueryRowContext(ctx, `PRAGMA quick_check(1)`).Scan(&result); err != nil {
+			return dbError(err)
+		}
+		if result != "ok" {
+			log.Printf("[corral] quick_check failed: %s", result)
+			return fmt.Errorf("%w: %s", ErrDatabaseCorrupt, result)
+		}
+	}
+	return nil
+}
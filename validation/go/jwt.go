@@ -0,0 +1,294 @@
+package corral
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384/512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTTL is how long a fetched key set is trusted before refetching.
+	jwksTTL = time.Hour
+	// jwksMinRefresh throttles refetches triggered by unknown key IDs.
+	jwksMinRefresh = time.Minute
+)
+
+var errUnknownKey = errors.New("corral: no JWKS key for token")
+
+// WithJWTVerification accepts Bearer tokens issued by Better Auth's jwt
+// plugin. A Bearer token shaped like a JWT is verified against the key set
+// at jwksURL (typically https://<app>/api/auth/jwks), its exp and nbf claims
+// are checked, and its sub claim is resolved with GetUserByID. Such tokens
+// never hit the session table; opaque tokens are validated as sessions as
+// before. The key set is cached and refetched when a token names an
+// unknown kid, so key rotation is picked up automatically.
+func WithJWTVerification(jwksURL string) Option {
+	return func(v *Validator) {
+		v.jwks = &jwksCache{url: jwksURL, client: &http.Client{Timeout: 5 * time.Second}}
+	}
+}
+
+// looksLikeJWT reports whether token has the three-part JWS compact form.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Sub string  `json:"sub"`
+	Exp float64 `json:"exp"`
+	Nbf float64 `json:"nbf"`
+}
+
+// authenticateJWT verifies token and returns its subject's user, or nil if
+// the token is invalid. Besides ErrUserBanned, only failures to fetch keys
+// or query the user are returned as errors.
+func (v *Validator) authenticateJWT(ctx context.Context, token string) (*User, error) {
+	parts := strings.Split(token, ".")
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, nil
+	}
+	key, err := v.jwks.key(ctx, header.Kid)
+	if errors.Is(err, errUnknownKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verifyJWS(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, nil
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil || claims.Sub == "" || claims.Exp == 0 {
+		return nil, nil
+	}
+	now := v.now()
+	if secs := float64(now.Unix()); secs >= claims.Exp || (claims.Nbf != 0 && secs < claims.Nbf) {
+		return nil, nil
+	}
+
+	db, err := v.openUsers()
+	if err != nil {
+		return nil, err
+	}
+	u, ban, err := v.getUserWithBan(ctx, db, claims.Sub)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	if ban.activeAt(u.ID, now) {
+		return nil, ErrUserBanned
+	}
+	return u, nil
+}
+
+func decodeJWTPart(part string, dst any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// verifyJWS checks sig over input for alg, rejecting algorithms that don't
+// match the key type.
+func verifyJWS(alg string, key crypto.PublicKey, input, sig []byte) bool {
+	switch alg {
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, input, sig)
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hash := map[string]crypto.Hash{"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512}[alg]
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		h := hash.New()
+		h.Write(input)
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, h.Sum(nil), r, s)
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[2:]]
+		h := hash.New()
+		h.Write(input)
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, hash, h.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), sig) == nil
+	}
+	return false
+}
+
+// jwksCache holds the verification keys from a JWKS endpoint by kid.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetched     time.Time
+	lastAttempt time.Time
+	lastErr     error         // of the last fetch
+	refreshing  chan struct{} // closed when the fetch in flight ends
+}
+
+// key returns the public key for kid, refetching the set when it is stale
+// or kid is unknown. An empty kid matches a set holding a single key. The
+// fetch runs without holding mu, and concurrent callers wait for the one
+// in flight (or use the stale key they already have) instead of fetching
+// again.
+func (c *jwksCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	k, ok := c.lookup(kid)
+	if ok && time.Since(c.fetched) < jwksTTL {
+		c.mu.Unlock()
+		return k, nil
+	}
+	if done := c.refreshing; done != nil {
+		c.mu.Unlock()
+		if ok {
+			return k, nil
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	} else if c.keys == nil || time.Since(c.lastAttempt) >= jwksMinRefresh {
+		c.lastAttempt = time.Now()
+		done := make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+		keys, err := c.fetch(ctx)
+		c.mu.Lock()
+		c.refreshing = nil
+		close(done)
+		c.lastErr = err
+		if err == nil {
+			c.keys, c.fetched = keys, time.Now()
+		} else if c.keys != nil {
+			log.Printf("[corral] JWKS refresh failed, using cached keys: %v", err)
+		}
+	}
+	defer c.mu.Unlock()
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	if c.keys == nil && c.lastErr != nil {
+		return nil, c.lastErr
+	}
+	return nil, errUnknownKey
+}
+
+func (c *jwksCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+// fetch downloads the key set. It does not touch the cache.
+func (c *jwksCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("corral: fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("corral: fetch JWKS: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("corral: decode JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	return keys, nil
+}
+
+// jwk is a JSON Web Key as served by Better Auth's /jwks endpoint.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "OKP":
+		x, err := b64.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("corral: unsupported OKP key %q", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("corral: unsupported EC curve %q", k.Crv)
+		}
+		x, errX := b64.DecodeString(k.X)
+		y, errY := b64.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("corral: malformed EC key %q", k.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "RSA":
+		n, errN := b64.DecodeString(k.N)
+		e, errE := b64.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("corral: malformed RSA key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("corral: unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,76 @@
+package corral
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKSFetchOutsideLock(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "OKP", "crv": "Ed25519", "kid": "k1", "x": base64.RawURLEncoding.EncodeToString(pub),
+		}}})
+	}))
+	defer srv.Close()
+	c := &jwksCache{url: srv.URL, client: srv.Client()}
+	ctx := context.Background()
+
+	if _, err := c.key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	// Unknown kids trigger one slow refetch; concurrent callers share it.
+	c.mu.Lock()
+	c.lastAttempt = time.Time{}
+	c.mu.Unlock()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.key(ctx, "k2"); err != errUnknownKey {
+				t.Errorf("key(k2) err = %v, want errUnknownKey", err)
+			}
+		}()
+	}
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The cached key stays available while the fetch is in flight.
+	got := make(chan error, 1)
+	go func() {
+		_, err := c.key(ctx, "k1")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("key(k1) blocked behind the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
@@ -0,0 +1,107 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lastActiveCol is the session column written by WithLastActiveTracking.
+// Better Auth doesn't create it; add it with
+// ALTER TABLE "session" ADD COLUMN "lastActiveAt" DATE.
+const lastActiveCol = `"lastActiveAt"`
+
+// WithLastActiveTracking makes Middleware record when each session was last
+// used. Uses are coalesced in memory and written to the session's
+// lastActiveAt column in one transaction every flushInterval, and once more
+// on Close, so activity tracking adds no per-request write. If the column
+// doesn't exist tracking logs once and turns itself off.
+func WithLastActiveTracking(flushInterval time.Duration) Option {
+	return func(v *Validator) {
+		v.activity = &activityTracker{interval: flushInterval}
+	}
+}
+
+// activityTracker holds the latest use time per session ID until flushed.
+type activityTracker struct {
+	interval time.Duration
+	missing  atomic.Bool
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+func (a *activityTracker) touch(sessionID string, now time.Time) {
+	if a.missing.Load() {
+		return
+	}
+	a.mu.Lock()
+	if a.pending == nil {
+		a.pending = make(map[string]time.Time)
+	}
+	a.pending[sessionID] = now
+	a.mu.Unlock()
+}
+
+// startActivityFlusher flushes on the tracker's interval and once more when
+// the validator shuts down.
+func (v *Validator) startActivityFlusher() {
+	v.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(v.activity.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				v.flushActivity(context.Background())
+				return
+			case <-ticker.C:
+				v.flushActivity(context.Background())
+			}
+		}
+	})
+}
+
+// flushActivity writes pending last-active times. On failure the batch is
+// dropped; the next request from each session re-queues it.
+func (v *Validator) flushActivity(ctx context.Context) {
+	a := v.activity
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	if len(pending) == 0 || a.missing.Load() {
+		return
+	}
+	if err := v.writeActivity(ctx, pending); err != nil {
+		if isMissingColumn(err) {
+			a.missing.Store(true)
+			log.Printf("[corral] Session table has no lastActiveAt column; last-active tracking disabled")
+			return
+		}
+		log.Printf("[corral] Failed to record last-active times for %d sessions: %v", len(pending), err)
+	}
+}
+
+func (v *Validator) writeActivity(ctx context.Context, pending map[string]time.Time) error {
+	db, err := v.querier()
+	if err != nil {
+		return err
+	}
+	tx, err := begin(ctx, db, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	sq := v.sq
+	query := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ?`,
+		sq.SessionTable, lastActiveCol, sq.SessionIDCol)
+	for id, at := range pending {
+		if _, err := tx.ExecContext(ctx, query, formatTime(at), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,43 @@
+package corral
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionalMiddlewareLockdownWithoutSession(t *testing.T) {
+	tests := []struct {
+		name      string
+		anonymous *User
+		allowed   []string
+		want      int
+	}{
+		{"no principal", nil, []string{"admin"}, http.StatusServiceUnavailable},
+		{"principal not allowed", &User{Role: "anonymous"}, []string{"admin"}, http.StatusServiceUnavailable},
+		{"principal allowed", &User{Role: "anonymous"}, []string{"admin", "anonymous"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.anonymous != nil {
+				opts = append(opts, WithAnonymousUser(tt.anonymous))
+			}
+			v, _ := newTestValidator(t, opts...)
+			h := v.OptionalMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("before lockdown: status = %d, want 200", rec.Code)
+			}
+
+			v.SetLockdown(true, tt.allowed...)
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != tt.want {
+				t.Fatalf("during lockdown: status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package corral
+
+// WithSessionLookupColumns sets the session columns a presented token is
+// matched against, tried in order with one query each so every lookup can
+// use its own index. Validation stops at the first unexpired match. The
+// default is the schema's token column; use ("token", "id") to also accept
+// clients whose cookie carries the session ID.
+func WithSessionLookupColumns(cols ...string) Option {
+	return func(v *Validator) {
+		v.lookupCols = cols
+	}
+}
+
+// resolveLookupCols checks and quotes cols, defaulting to the resolved
+// schema's token column.
+func resolveLookupCols(sq Schema, cols []string) ([]string, error) {
+	if len(cols) == 0 {
+		return []string{sq.TokenCol}, nil
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		if err := checkIdent(c); err != nil {
+			return nil, err
+		}
+		quoted[i] = quoteIdent(c)
+	}
+	return quoted, nil
+}
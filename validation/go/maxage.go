@@ -0,0 +1,18 @@
+package corral
+
+import "time"
+
+// WithAbsoluteMaxSessionAge rejects sessions, as expired, once they are
+// more than d past their createdAt, however far out expiresAt is. Sliding
+// expiration never extends a session beyond that ceiling. Sessions with no
+// createdAt count as infinitely old.
+func WithAbsoluteMaxSessionAge(d time.Duration) Option {
+	return func(v *Validator) {
+		v.maxSessionAge = d
+	}
+}
+
+// tooOld reports whether s has outlived WithAbsoluteMaxSessionAge as of now.
+func (v *Validator) tooOld(s *Session, now time.Time) bool {
+	return v.maxSessionAge > 0 && now.Sub(s.CreatedAt) > v.maxSessionAge
+}
@@ -0,0 +1,117 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAbsoluteMaxSessionAge(t *testing.T) {
+	const maxAge = 30 * 24 * time.Hour
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		age     time.Duration
+		wantErr error
+	}{
+		{"new", time.Hour, nil},
+		{"within", maxAge - time.Millisecond, nil},
+		{"at the cap", maxAge, nil},
+		{"beyond", maxAge + time.Millisecond, ErrSessionExpired},
+		{"far beyond", 2 * maxAge, ErrSessionExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, db := newTestValidator(t,
+				WithClock(func() time.Time { return now }),
+				WithAbsoluteMaxSessionAge(maxAge))
+			insertUser(t, db, "u1", "free", "user")
+			// expiresAt is far out, so only the age can reject the session.
+			insertSession(t, db, "tok1", "u1", now.Add(-tt.age), now.Add(365*24*time.Hour))
+
+			u, _, err := v.authenticate(context.Background(), "tok1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if (u != nil) != (tt.wantErr == nil) {
+				t.Errorf("user = %v with err %v", u, err)
+			}
+		})
+	}
+}
+
+func TestAbsoluteMaxSessionAgeCached(t *testing.T) {
+	const maxAge = 30 * 24 * time.Hour
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := now
+	v, db := newTestValidator(t,
+		WithClock(func() time.Time { return clock }),
+		WithAbsoluteMaxSessionAge(maxAge),
+		WithSessionCache(time.Hour, 16))
+	insertUser(t, db, "u1", "free", "user")
+	insertSession(t, db, "tok1", "u1", now.Add(-maxAge+time.Minute), now.Add(365*24*time.Hour))
+
+	ctx := context.Background()
+	if _, _, err := v.authenticate(ctx, "tok1"); err != nil {
+		t.Fatalf("within the cap: %v", err)
+	}
+	// Still cached, but now past the cap.
+	clock = now.Add(2 * time.Minute)
+	if _, _, err := v.authenticate(ctx, "tok1"); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("beyond the cap: err = %v, want ErrSessionExpired", err)
+	}
+}
+
+func TestAbsoluteMaxSessionAgeCapsRefresh(t *testing.T) {
+	const maxAge = 10 * 24 * time.Hour
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-9 * 24 * time.Hour)
+	v, db := newTestValidator(t,
+		WithClock(func() time.Time { return now }),
+		WithAbsoluteMaxSessionAge(maxAge),
+		WithSlidingExpiration(7*24*time.Hour, 24*time.Hour))
+	insertUser(t, db, "u1", "free", "user")
+	insertSession(t, db, "tok1", "u1", created, now.Add(time.Hour))
+
+	_, s, err := v.ValidateSessionFull(context.Background(), "tok1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := created.Add(maxAge); !s.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want the cap %v", s.ExpiresAt, want)
+	}
+}
+
+func TestResolveUserIDAbsoluteMaxSessionAge(t *testing.T) {
+	const maxAge = 30 * 24 * time.Hour
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, cached := range []bool{false, true} {
+		clock := now
+		opts := []Option{
+			WithClock(func() time.Time { return clock }),
+			WithAbsoluteMaxSessionAge(maxAge),
+		}
+		if cached {
+			opts = append(opts, WithSessionCache(time.Hour, 16))
+		}
+		v, db := newTestValidator(t, opts...)
+		insertUser(t, db, "u1", "free", "user")
+		insertSession(t, db, "tok1", "u1", now.Add(-maxAge+time.Minute), now.Add(365*24*time.Hour))
+
+		ctx := context.Background()
+		if cached {
+			// Fill the cache while the session is within the cap.
+			if _, _, err := v.authenticate(ctx, "tok1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if id, err := v.ResolveUserID(ctx, "tok1"); err != nil || id != "u1" {
+			t.Fatalf("cached=%v within the cap: ResolveUserID = %q, %v; want u1", cached, id, err)
+		}
+		clock = now.Add(2 * time.Minute)
+		if id, err := v.ResolveUserID(ctx, "tok1"); err != nil || id != "" {
+			t.Errorf("cached=%v beyond the cap: ResolveUserID = %q, %v; want \"\"", cached, id, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// MultiValidator tries several validators in order, for running two
+// session stores side by side during a migration. The first to return a
+// User wins; if none does, their errors are joined.
+type MultiValidator struct {
+	validators []SessionValidator
+}
+
+var _ SessionValidator = (*MultiValidator)(nil)
+
+// NewMultiValidator returns a MultiValidator trying vs in order.
+func NewMultiValidator(vs ...SessionValidator) *MultiValidator {
+	return &MultiValidator{validators: vs}
+}
+
+// ValidateSessionContext validates token against each validator in turn.
+// A banned user stops the search.
+func (m *MultiValidator) ValidateSessionContext(ctx context.Context, token string) (*User, error) {
+	return m.first(func(v SessionValidator) (*User, error) {
+		return v.ValidateSessionContext(ctx, token)
+	})
+}
+
+// ValidateRequest resolves r against each validator in turn.
+func (m *MultiValidator) ValidateRequest(r *http.Request) (*User, error) {
+	return m.first(func(v SessionValidator) (*User, error) {
+		return v.ValidateRequest(r)
+	})
+}
+
+func (m *MultiValidator) first(validate func(SessionValidator) (*User, error)) (*User, error) {
+	var errs []error
+	for _, v := range m.validators {
+		u, err := validate(v)
+		if u != nil {
+			return u, nil
+		}
+		if errors.Is(err, ErrUserBanned) {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Middleware sets the User from the first validator that accepts the
+// request and returns 401 otherwise. Unlike Validator.Middleware it does
+// not set the Session or refresh cookies.
+func (m *MultiValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := m.ValidateRequest(r)
+		if errors.Is(err, ErrUserBanned) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if u == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, u)))
+	})
+}
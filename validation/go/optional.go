@@ -0,0 +1,69 @@
+package corral
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// WithAnonymousUser makes OptionalMiddleware store a copy of u in the
+// request context when there is no valid session, so handlers always get a
+// non-nil User. The copy is marked so that IsAnonymous tells it apart from
+// an authenticated user whatever its fields, e.g.
+//
+//	corral.WithAnonymousUser(&corral.User{Plan: "free", Role: "anonymous"})
+func WithAnonymousUser(u *User) Option {
+	return func(v *Validator) {
+		v.anonymous = u
+	}
+}
+
+// IsAnonymous reports whether u is missing or the WithAnonymousUser
+// principal stored by OptionalMiddleware rather than an authenticated user.
+// Users without an ID, such as service-token principals, are not anonymous.
+func (u *User) IsAnonymous() bool {
+	return u == nil || u.anonymous
+}
+
+// OptionalMiddleware is like Middleware but lets requests without a valid
+// session through instead of rejecting them. Handlers see the User from
+// UserFromContext when authentication succeeded, and nil (or the
+// WithAnonymousUser principal) otherwise. During SetLockdown, requests
+// without a session get the same 503 as users outside the allowlist, unless
+// the allowlist names the WithAnonymousUser principal's role.
+func (v *Validator) OptionalMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v.skipAuth(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, _ := v.authenticateRequest(r)
+		if res.user == nil {
+			if v.anonymousLockedOut() {
+				v.lockdownUnavailable(w)
+				return
+			}
+			ctx := v.withAnonymous(r.Context())
+			if res.requestID != "" {
+				ctx = context.WithValue(ctx, requestIDContextKey{}, res.requestID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		v.serveAuthenticated(w, r, res, next)
+	})
+}
+
+// withAnonymous stores a copy of the WithAnonymousUser principal in ctx, if
+// one is configured.
+func (v *Validator) withAnonymous(ctx context.Context) context.Context {
+	if v.anonymous == nil {
+		return ctx
+	}
+	u := *v.anonymous
+	u.Extra = maps.Clone(u.Extra)
+	u.Roles = slices.Clone(u.Roles)
+	u.anonymous = true
+	return context.WithValue(ctx, contextKey{}, &u)
+}
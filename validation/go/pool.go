@@ -0,0 +1,63 @@
+package corral
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Pool settings. Zero values keep the database/sql defaults. For SQLite,
+// a small pool (e.g. WithMaxOpenConns(4), WithMaxIdleConns(4)) is usually
+// best: reads scale a little with connections, but writes (sliding refresh,
+// revocation) serialize on the database lock, and more connections only
+// add SQLITE_BUSY contention.
+
+// WithMaxOpenConns limits the number of open connections in the pool.
+func WithMaxOpenConns(n int) Option {
+	return func(v *Validator) {
+		v.pool.maxOpen = n
+	}
+}
+
+// WithMaxIdleConns sets how many idle connections the pool keeps.
+func WithMaxIdleConns(n int) Option {
+	return func(v *Validator) {
+		v.pool.maxIdle = n
+	}
+}
+
+// WithConnMaxLifetime closes connections after they have been open for d.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(v *Validator) {
+		v.pool.maxLifetime = d
+	}
+}
+
+// poolConfig holds the pool settings applied when the pool is opened.
+type poolConfig struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+func (c poolConfig) apply(db *sql.DB) {
+	if c.maxOpen > 0 {
+		db.SetMaxOpenConns(c.maxOpen)
+	}
+	if c.maxIdle > 0 {
+		db.SetMaxIdleConns(c.maxIdle)
+	}
+	if c.maxLifetime > 0 {
+		db.SetConnMaxLifetime(c.maxLifetime)
+	}
+}
+
+// PoolStats returns statistics for the validator's connection pool, or the
+// zero value if it has not been opened yet.
+func (v *Validator) PoolStats() sql.DBStats {
+	v.dbMu.Lock()
+	defer v.dbMu.Unlock()
+	if v.db == nil {
+		return sql.DBStats{}
+	}
+	return v.db.Stats()
+}
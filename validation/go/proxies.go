@@ -0,0 +1,110 @@
+package corral
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies lists the reverse proxies, as CIDRs or single IPs,
+// whose forwarding headers are believed. X-Forwarded-For and
+// X-Forwarded-Proto are honored only on requests whose RemoteAddr is in
+// one of the ranges; the client IP used by the rate limits and
+// concurrent-use detection is then taken from X-Forwarded-For. Without it
+// forwarding headers are ignored: the client IP is RemoteAddr and only
+// r.TLS counts as HTTPS, so set it whenever the validator sits behind a
+// TLS-terminating proxy. An invalid entry is a configuration error.
+func WithTrustedProxies(cidrs []string) Option {
+	return func(v *Validator) {
+		v.proxyCIDRs = cidrs
+	}
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		p, err := parsePrefix(c)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("corral: trusted proxy %q: %w", s, err)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("corral: trusted proxy %q: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// trusted reports whether ip belongs to a trusted proxy.
+func (v *Validator) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range v.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client that sent r: the host part of
+// r.RemoteAddr or, when that is a trusted proxy, the right-most
+// X-Forwarded-For entry that isn't one.
+func (v *Validator) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !v.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !v.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// isSecureRequest reports whether r arrived over TLS, either directly or
+// via a trusted proxy (see WithTrustedProxies) that terminated TLS and set
+// X-Forwarded-Proto.
+func (v *Validator) isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !v.trusted(remoteIP(r)) {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// remoteIP returns the host part of r.RemoteAddr.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
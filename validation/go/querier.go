@@ -0,0 +1,87 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is the database access the validator needs. *sql.DB and *sql.Tx
+// implement it, and so can decorators adding tracing, metrics or retries.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// WithQuerier sends all queries on the main database through q, typically
+// a decorator around a pool the caller opened, instead of a pool the
+// validator opens itself. The caller keeps ownership: Close leaves it
+// open. Transactions (WithConsistentRead, RotateSession and other
+// multi-statement writes) are only used if q also has *sql.DB's BeginTx;
+// otherwise their statements run one by one, and those on the *sql.Tx are
+// not decorated. WithUserDB and WithUserDBPath still read users directly.
+func WithQuerier(q Querier) Option {
+	return func(v *Validator) {
+		v.customQ = q
+	}
+}
+
+// querier returns the WithQuerier Querier, or the shared pool.
+func (v *Validator) querier() (Querier, error) {
+	if v.customQ == nil {
+		return v.open()
+	}
+	if v.configErr != nil {
+		return nil, v.configErr
+	}
+	return v.customQ, nil
+}
+
+// txBeginner is implemented by Queriers that can start transactions.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// txn is a transaction on a Querier, or the Querier itself if it can't
+// start one, in which case Commit and Rollback do nothing.
+type txn struct {
+	Querier
+	tx *sql.Tx
+}
+
+func (t txn) Commit() error {
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Commit()
+}
+
+func (t txn) Rollback() error {
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Rollback()
+}
+
+// begin starts a transaction on q when q supports them.
+func begin(ctx context.Context, q Querier, opts *sql.TxOptions) (txn, error) {
+	b, ok := q.(txBeginner)
+	if !ok {
+		return txn{Querier: q}, nil
+	}
+	tx, err := b.BeginTx(ctx, opts)
+	if err != nil {
+		return txn{}, err
+	}
+	return txn{Querier: tx, tx: tx}, nil
+}
+
+// ping checks that q can reach the database, using PingContext if q has
+// it and a trivial query otherwise.
+func ping(ctx context.Context, q Querier) error {
+	if p, ok := q.(interface{ PingContext(context.Context) error }); ok {
+		return p.PingContext(ctx)
+	}
+	var one int
+	return q.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+}
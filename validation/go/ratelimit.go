@@ -0,0 +1,164 @@
+package corral
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultRateLimit applies to plans without an entry in WithPlanRateLimits.
+const DefaultRateLimit rate.Limit = 10
+
+const (
+	limiterIdleTTL       = 10 * time.Minute
+	limiterSweepInterval = time.Minute
+
+	// anonymousLimiterPrefix keys the buckets of the WithAnonymousUser
+	// principal by client IP, apart from user IDs.
+	anonymousLimiterPrefix = "anonymous\x00"
+)
+
+// WithPlanRateLimits sets per-plan request rates (events per second) enforced
+// by RateLimitMiddleware. Each user gets a token bucket sized by their plan,
+// with a burst equal to one second's worth of requests. Plans without an entry
+// use DefaultRateLimit, or the value passed to WithDefaultRateLimit.
+func WithPlanRateLimits(limits map[string]rate.Limit) Option {
+	return func(v *Validator) {
+		v.limiters.plans = limits
+	}
+}
+
+// WithDefaultRateLimit overrides DefaultRateLimit for plans without an entry
+// in WithPlanRateLimits.
+func WithDefaultRateLimit(limit rate.Limit) Option {
+	return func(v *Validator) {
+		v.limiters.fallback = limit
+		v.limiters.hasFallback = true
+	}
+}
+
+// RateLimitMiddleware throttles each authenticated user according to their
+// plan and returns 429 with Retry-After once the user's bucket is empty.
+// It reads the user set by Middleware or OptionalMiddleware; when mounted on
+// its own it validates the session first, exactly as Middleware would. The
+// WithAnonymousUser principal is limited by its plan under one bucket per
+// client IP rather than per user.
+func (v *Validator) RateLimitMiddleware(next http.Handler) http.Handler {
+	v.limiters.startSweeper(v)
+	limited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := UserFromContext(r.Context())
+		if user == nil {
+			// Skipped by WithSkipPaths/WithSkipFunc.
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := user.ID
+		if user.IsAnonymous() {
+			key = anonymousLimiterPrefix + v.clientIP(r)
+		}
+		if delay, ok := v.limiters.reserve(key, user.Plan, v.now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if UserFromContext(r.Context()) == nil {
+			v.Middleware(limited).ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+// planLimiters holds one token bucket per user ID (or anonymous client IP),
+// evicting idle ones.
+type planLimiters struct {
+	plans       map[string]rate.Limit
+	fallback    rate.Limit
+	hasFallback bool
+
+	mu      sync.Mutex
+	users   map[string]*userLimiter
+	sweeper sync.Once
+}
+
+type userLimiter struct {
+	plan     string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func (p *planLimiters) limitFor(plan string) rate.Limit {
+	if l, ok := p.plans[plan]; ok {
+		return l
+	}
+	if p.hasFallback {
+		return p.fallback
+	}
+	return DefaultRateLimit
+}
+
+// reserve takes a token from key's bucket, sized for plan. If none is
+// available it returns how long until one will be, and false.
+func (p *planLimiters) reserve(key, plan string, now time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.users == nil {
+		p.users = make(map[string]*userLimiter)
+	}
+	ul := p.users[key]
+	if ul == nil || ul.plan != plan {
+		limit := p.limitFor(plan)
+		burst := int(math.Ceil(float64(limit)))
+		if burst < 1 {
+			burst = 1
+		}
+		ul = &userLimiter{plan: plan, limiter: rate.NewLimiter(limit, burst)}
+		p.users[key] = ul
+	}
+	ul.lastSeen = now
+
+	res := ul.limiter.ReserveN(now, 1)
+	if !res.OK() {
+		return time.Second, false
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+func (p *planLimiters) startSweeper(v *Validator) {
+	p.sweeper.Do(func() {
+		v.goBackground(func(stop <-chan struct{}) {
+			ticker := time.NewTicker(limiterSweepInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					p.evictIdle(v.now())
+				}
+			}
+		})
+	})
+}
+
+func (p *planLimiters) evictIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, ul := range p.users {
+		if now.Sub(ul.lastSeen) > limiterIdleTTL {
+			delete(p.users, id)
+		}
+	}
+}
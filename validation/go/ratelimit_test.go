@@ -0,0 +1,34 @@
+package corral
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitAnonymousPrincipal(t *testing.T) {
+	v, _ := newTestValidator(t,
+		WithAnonymousUser(&User{Plan: "free", Role: "anonymous"}),
+		WithPlanRateLimits(map[string]rate.Limit{"free": 1}),
+	)
+	h := v.OptionalMiddleware(v.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	for _, tt := range []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.1:1234", http.StatusTooManyRequests},
+		{"192.0.2.2:1234", http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.want {
+			t.Fatalf("request from %s: status = %d, want %d", tt.remoteAddr, rec.Code, tt.want)
+		}
+	}
+}
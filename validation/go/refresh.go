@@ -0,0 +1,254 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CookieOptions are the attributes used when writing the session cookie.
+// They should mirror the Better Auth server's cookie configuration.
+type CookieOptions struct {
+	Path     string // defaults to "/"
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite // defaults to http.SameSiteLaxMode
+}
+
+// WithSlidingExpiration enables Better Auth-style session refresh: once a
+// session is more than updateAge past its last refresh, validation pushes
+// its expiresAt to now+expiresIn. Use the same values as the Better Auth
+// server's session.expiresIn and session.updateAge.
+func WithSlidingExpiration(expiresIn, updateAge time.Duration) Option {
+	return func(v *Validator) {
+		v.expiresIn = expiresIn
+		v.updateAge = updateAge
+	}
+}
+
+// WithRefreshCookie makes Middleware re-issue the session cookie with the
+// new expiry whenever a session is refreshed.
+func WithRefreshCookie(enabled bool) Option {
+	return func(v *Validator) {
+		v.refreshCookie = enabled
+	}
+}
+
+// WithCookieOptions sets the attributes of cookies written by the validator.
+func WithCookieOptions(opts CookieOptions) Option {
+	return func(v *Validator) {
+		v.cookieOptions = opts
+	}
+}
+
+// maybeRefresh extends s when sliding expiration is enabled and the session
+// is due as of now, setting s.Refreshed. A failed update is logged and the
+// session is still honored with its old expiry.
+func (v *Validator) maybeRefresh(ctx context.Context, db Querier, s *Session, now time.Time) {
+	if !v.refreshDue(s, now) {
+		return
+	}
+	expiresAt := now.Add(v.expiresIn)
+	if ceiling := s.CreatedAt.Add(v.maxSessionAge); v.maxSessionAge > 0 && ceiling.Before(expiresAt) {
+		if !ceiling.After(s.ExpiresAt) {
+			return
+		}
+		expiresAt = ceiling
+	}
+	sq := v.sq
+	_, err := db.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE %s SET %s = ?, %s = ? WHERE %s = ?`,
+			sq.SessionTable, sq.ExpiresCol, sq.SessionUpdatedCol, sq.SessionIDCol),
+		formatTime(expiresAt), formatTime(now), s.ID,
+	)
+	if err != nil {
+		log.Printf("[corral] Failed to refresh session %s: %v", s.ID, err)
+		return
+	}
+	s.ExpiresAt = expiresAt
+	s.Refreshed = true
+}
+
+// refreshDue reports whether sliding expiration should extend s as of now.
+func (v *Validator) refreshDue(s *Session, now time.Time) bool {
+	return v.expiresIn > 0 && !s.ExpiresAt.Add(-v.expiresIn).Add(v.updateAge).After(now)
+}
+
+// ExtendSession sets token's session to expire newTTL from now, for an
+// explicit "keep me logged in" action, and returns the new expiry for the
+// cookie. It fails with ErrSessionNotFound or ErrSessionExpired if there is
+// no live session to extend.
+func (v *Validator) ExtendSession(ctx context.Context, token string, newTTL time.Duration) (time.Time, error) {
+	if newTTL <= 0 {
+		return time.Time{}, errors.New("corral: session ttl must be positive")
+	}
+	db, err := v.querier()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := v.now()
+	s, err := v.lookupSession(ctx, db, token, now)
+	if err != nil {
+		return time.Time{}, dbError(err)
+	}
+	if s == nil {
+		return time.Time{}, ErrSessionNotFound
+	}
+	if s.ExpiresAt.Before(now) {
+		return time.Time{}, ErrSessionExpired
+	}
+	expiresAt := now.Add(newTTL)
+	sq := v.sq
+	_, err = db.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE %s SET %s = ?, %s = ? WHERE %s = ?`,
+			sq.SessionTable, sq.ExpiresCol, sq.SessionUpdatedCol, sq.SessionIDCol),
+		formatTime(expiresAt), formatTime(now), s.ID,
+	)
+	if err != nil {
+		return time.Time{}, dbError(err)
+	}
+	v.invalidate(ctx, token)
+	return expiresAt, nil
+}
+
+// RotateSession replaces token with a freshly generated one, for use after a
+// privilege change to prevent session fixation. The session keeps its ID,
+// user and creation time; its expiry is pushed out by the sliding
+// expiration lifetime, or by its original lifetime without one. The swap
+// is a single transactional update, so exactly one of the two tokens is
+// valid at any moment. The caller re-sets the cookie with the new token.
+func (v *Validator) RotateSession(ctx context.Context, oldToken string) (string, time.Time, error) {
+	db, err := v.querier()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := v.now()
+	s, err := v.lookupSession(ctx, db, oldToken, now)
+	if err != nil {
+		return "", time.Time{}, dbError(err)
+	}
+	if s == nil {
+		return "", time.Time{}, ErrSessionNotFound
+	}
+	if s.ExpiresAt.Before(now) {
+		return "", time.Time{}, ErrSessionExpired
+	}
+	expiresAt := s.ExpiresAt
+	if v.expiresIn > 0 {
+		expiresAt = now.Add(v.expiresIn)
+	} else if !s.CreatedAt.IsZero() {
+		expiresAt = now.Add(s.ExpiresAt.Sub(s.CreatedAt))
+	}
+	newToken, err := generateID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tx, err := begin(ctx, db, nil)
+	if err != nil {
+		return "", time.Time{}, dbError(err)
+	}
+	defer tx.Rollback()
+	sq := v.sq
+	// Matching on the old token too means a concurrent rotation or
+	// revocation makes this one fail instead of resurrecting the session.
+	res, err := tx.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE %s SET %s = ?, %s = ?, %s = ? WHERE %s = ? AND %s = ?`,
+			sq.SessionTable, sq.TokenCol, sq.ExpiresCol, sq.SessionUpdatedCol, sq.SessionIDCol, sq.TokenCol),
+		v.dbToken(newToken), formatTime(expiresAt), formatTime(now), s.ID, s.Token,
+	)
+	if err != nil {
+		return "", time.Time{}, dbError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return "", time.Time{}, dbError(err)
+	} else if n == 0 {
+		return "", time.Time{}, ErrSessionNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return "", time.Time{}, dbError(err)
+	}
+	v.invalidate(ctx, oldToken)
+	return newToken, expiresAt, nil
+}
+
+// sessionCookie builds the session cookie carrying token until expiresAt.
+func (v *Validator) sessionCookie(token string, expiresAt time.Time) *http.Cookie {
+	opts := v.cookieOptions.withDefaults()
+	return &http.Cookie{
+		Name:     v.cookieNames[0],
+		Value:    token,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Expires:  expiresAt,
+		MaxAge:   int(expiresAt.Sub(v.now()).Seconds()),
+		Secure:   opts.Secure,
+		HttpOnly: true,
+		SameSite: opts.SameSite,
+	}
+}
+
+// ClearSessionCookie expires the default Better Auth session cookies on w,
+// for use after RevokeSession so the browser drops the token. opts must
+// match the attributes the cookies were set with; with opts.Secure the
+// "__Secure-" prefixed names are cleared too. Validators configured with
+// WithCookieNames should use Validator.ClearSessionCookie instead.
+func ClearSessionCookie(w http.ResponseWriter, opts CookieOptions) {
+	names := []string{CookieName, SessionDataCookieName}
+	if opts.Secure {
+		names = append(names, "__Secure-"+CookieName, "__Secure-"+SessionDataCookieName)
+	}
+	clearCookies(w, names, opts)
+}
+
+// ClearSessionCookie is like the package-level ClearSessionCookie but
+// expires every name set with WithCookieNames, using WithCookieOptions.
+func (v *Validator) ClearSessionCookie(w http.ResponseWriter) {
+	names := append(slices.Clone(v.cookieNames), SessionDataCookieName)
+	if v.cookieOptions.Secure {
+		names = append(names, "__Secure-"+SessionDataCookieName)
+	}
+	clearCookies(w, names, v.cookieOptions)
+}
+
+func clearCookies(w http.ResponseWriter, names []string, opts CookieOptions) {
+	opts = opts.withDefaults()
+	for _, name := range names {
+		if strings.HasPrefix(name, "__Secure-") && !opts.Secure {
+			// Browsers ignore these without Secure.
+			continue
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     opts.Path,
+			Domain:   opts.Domain,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   opts.Secure,
+			HttpOnly: true,
+			SameSite: opts.SameSite,
+		})
+	}
+}
+
+// withDefaults fills in the Path and SameSite defaults.
+func (opts CookieOptions) withDefaults() CookieOptions {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return opts
+}
+
+// formatTime renders t the way Better Auth stores dates in SQLite.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
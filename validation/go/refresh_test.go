@@ -0,0 +1,77 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSlidingExpirationBoundary(t *testing.T) {
+	const (
+		expiresIn = 7 * 24 * time.Hour
+		updateAge = 24 * time.Hour
+	)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		refreshed bool
+	}{
+		{"exactly due", now.Add(expiresIn - updateAge), true},
+		{"past due", now.Add(expiresIn - updateAge - time.Millisecond), true},
+		{"not yet due", now.Add(expiresIn - updateAge + time.Millisecond), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, db := newTestValidator(t,
+				WithClock(func() time.Time { return now }),
+				WithSlidingExpiration(expiresIn, updateAge))
+			insertUser(t, db, "u1", "free", "user")
+			insertSession(t, db, "tok1", "u1", now.Add(-time.Hour), tt.expiresAt)
+
+			_, s, err := v.ValidateSessionFull(context.Background(), "tok1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Refreshed != tt.refreshed {
+				t.Fatalf("Refreshed = %v, want %v", s.Refreshed, tt.refreshed)
+			}
+			want := tt.expiresAt
+			if tt.refreshed {
+				want = now.Add(expiresIn)
+			}
+			if !s.ExpiresAt.Equal(want) {
+				t.Errorf("ExpiresAt = %v, want %v", s.ExpiresAt, want)
+			}
+
+			checkStored(t, db, want)
+
+			// The session is extended exactly once: validating again at the
+			// same instant leaves it alone.
+			_, s, err = v.ValidateSessionFull(context.Background(), "tok1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Refreshed {
+				t.Fatal("second validation: Refreshed = true, want false")
+			}
+			if !s.ExpiresAt.Equal(want) {
+				t.Errorf("second validation: ExpiresAt = %v, want %v", s.ExpiresAt, want)
+			}
+			checkStored(t, db, want)
+		})
+	}
+}
+
+// checkStored asserts the expiry stored for tok1.
+func checkStored(t *testing.T, db *sql.DB, want time.Time) {
+	t.Helper()
+	var stored string
+	if err := db.QueryRow(`SELECT expiresAt FROM session WHERE token = ?`, "tok1").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != formatTime(want) {
+		t.Errorf("stored expiresAt = %s, want %s", stored, formatTime(want))
+	}
+}
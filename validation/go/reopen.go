@@ -0,0 +1,43 @@
+package corral
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// SQLite result codes for a database file that is gone or was replaced.
+const (
+	sqliteCantOpen      = 14
+	sqliteReadonlyMoved = 1032 // SQLITE_READONLY_DBMOVED
+)
+
+// isStaleDB reports whether err means the pool is holding connections to a
+// database file that was moved, deleted or replaced (SQLITE_CANTOPEN or
+// SQLITE_READONLY_DBMOVED), so reopening the pool may recover. Schema
+// errors such as "no such table" are not: a reopen would not fix them, and
+// retrying them would reopen the pool on every request.
+func isStaleDB(err error) bool {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		c := coded.Code()
+		return c == sqliteReadonlyMoved || c&0xff == sqliteCantOpen
+	}
+	// A concurrent reopen closed the pool this query picked up; the retry
+	// runs on the new pool and reopen leaves it alone.
+	return strings.Contains(err.Error(), "sql: database is closed")
+}
+
+// reopen drops stale, the pool a query just failed on, so the next open
+// starts a fresh one against the current file at the configured path. If
+// another request already replaced it, the current pool is kept.
+func (v *Validator) reopen(stale *sql.DB) {
+	v.dbMu.Lock()
+	defer v.dbMu.Unlock()
+	if v.db != stale || stale == nil {
+		return
+	}
+	v.db = nil
+	// Close lets queries already running on the old pool finish.
+	go stale.Close()
+}
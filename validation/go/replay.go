@@ -0,0 +1,95 @@
+package corral
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NonceHeader carries the one-time request nonce checked by
+// ReplayProtectionMiddleware.
+const NonceHeader = "X-Request-Nonce"
+
+// NonceStore records request nonces. SeenBefore records nonce and reports
+// whether it had already been recorded; it must be atomic across
+// instances sharing the store.
+type NonceStore interface {
+	SeenBefore(nonce string) bool
+}
+
+// WithReplayProtection sets the store used by ReplayProtectionMiddleware.
+func WithReplayProtection(store NonceStore) Option {
+	return func(v *Validator) {
+		v.nonces = store
+	}
+}
+
+// ReplayProtectionMiddleware requires unsafe requests (anything but GET,
+// HEAD, OPTIONS and TRACE) to carry an unused X-Request-Nonce, answering
+// 400 when it is missing and 409 when it was seen before. Nonces are
+// scoped to the User set by an enclosing Middleware, if any. Without
+// WithReplayProtection it passes every request through.
+func (v *Validator) ReplayProtectionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v.nonces == nil || safeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		nonce := r.Header.Get(NonceHeader)
+		if nonce == "" {
+			v.writeError(w, http.StatusBadRequest, "missing request nonce", ErrNonceMissing)
+			return
+		}
+		if u := UserFromContext(r.Context()); u != nil {
+			nonce = u.ID + ":" + nonce
+		}
+		if v.nonces.SeenBefore(nonce) {
+			v.writeError(w, http.StatusConflict, "request replayed", ErrNonceReplayed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// MemoryNonceStore is an in-process NonceStore that forgets nonces after a
+// TTL. It only protects a single instance.
+type MemoryNonceStore struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryNonceStore returns a MemoryNonceStore remembering nonces for ttl.
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// SeenBefore implements NonceStore.
+func (s *MemoryNonceStore) SeenBefore(nonce string) bool {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= s.ttl {
+		for n, at := range s.seen {
+			if now.Sub(at) >= s.ttl {
+				delete(s.seen, n)
+			}
+		}
+		s.lastSweep = now
+	}
+	if at, ok := s.seen[nonce]; ok && now.Sub(at) < s.ttl {
+		return true
+	}
+	s.seen[nonce] = now
+	return false
+}
@@ -0,0 +1,41 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+)
+
+// maxRequestIDLength caps request IDs copied from client headers.
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// WithRequestIDHeader makes the middlewares read a request ID from header
+// (e.g. "X-Request-Id"), or generate a short random one when it is absent
+// or malformed. The ID appears in failure logs and ValidationEvent, and is
+// available to handlers from RequestIDFromContext.
+func WithRequestIDHeader(header string) Option {
+	return func(v *Validator) {
+		v.requestIDHeader = header
+	}
+}
+
+// RequestIDFromContext returns the request ID set by the middlewares with
+// WithRequestIDHeader, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// requestID returns r's request ID, or "" without WithRequestIDHeader.
+func (v *Validator) requestID(r *http.Request) string {
+	if v.requestIDHeader == "" {
+		return ""
+	}
+	if id := r.Header.Get(v.requestIDHeader); id != "" && len(id) <= maxRequestIDLength && wellFormedToken(id) {
+		return id
+	}
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
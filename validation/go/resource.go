@@ -0,0 +1,46 @@
+package corral
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAuthServerNotRunning is returned when querying a managed auth server
+// that has not been started or has exited.
+var ErrAuthServerNotRunning = errors.New("corral: auth server not running")
+
+// ResourceUsage is the resource consumption of the managed auth server,
+// summed over replicas.
+type ResourceUsage struct {
+	CPUTime time.Duration // user + system time since start
+	RSS     int64         // resident set size in bytes
+}
+
+// AuthServerResourceUsage reports the CPU time and memory of the running
+// auth server processes. It is only implemented on Linux, where it reads
+// /proc; elsewhere it returns errors.ErrUnsupported.
+func (v *Validator) AuthServerResourceUsage() (ResourceUsage, error) {
+	var total ResourceUsage
+	running := 0
+	for _, p := range v.authServers() {
+		if p.cmd == nil {
+			continue
+		}
+		select {
+		case <-p.exited:
+			continue
+		default:
+		}
+		u, err := processUsage(p.cmd.Process.Pid)
+		if err != nil {
+			return ResourceUsage{}, err
+		}
+		total.CPUTime += u.CPUTime
+		total.RSS += u.RSS
+		running++
+	}
+	if running == 0 {
+		return ResourceUsage{}, ErrAuthServerNotRunning
+	}
+	return total, nil
+}
@@ -0,0 +1,53 @@
+package corral
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux platform Go supports.
+const clockTicks = 100
+
+// processUsage reads pid's CPU time from /proc/<pid>/stat and its resident
+// set from /proc/<pid>/statm.
+func processUsage(pid int) (ResourceUsage, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	// The command name may contain spaces; fields resume after its ")".
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return ResourceUsage{}, fmt.Errorf("corral: malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 13 {
+		return ResourceUsage{}, fmt.Errorf("corral: malformed /proc/%d/stat", pid)
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return ResourceUsage{}, fmt.Errorf("corral: malformed /proc/%d/stat", pid)
+	}
+
+	statm, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	mem := strings.Fields(string(statm))
+	if len(mem) < 2 {
+		return ResourceUsage{}, fmt.Errorf("corral: malformed /proc/%d/statm", pid)
+	}
+	pages, err := strconv.ParseInt(mem[1], 10, 64)
+	if err != nil {
+		return ResourceUsage{}, fmt.Errorf("corral: malformed /proc/%d/statm", pid)
+	}
+
+	return ResourceUsage{
+		CPUTime: time.Duration(utime+stime) * time.Second / clockTicks,
+		RSS:     pages * int64(os.Getpagesize()),
+	}, nil
+}
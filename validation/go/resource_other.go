@@ -0,0 +1,9 @@
+//go:build !linux
+
+package corral
+
+import "errors"
+
+func processUsage(pid int) (ResourceUsage, error) {
+	return ResourceUsage{}, errors.ErrUnsupported
+}
@@ -0,0 +1,174 @@
+package corral
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema names the tables and columns the validator queries. Fields left
+// empty take their value from DefaultSchema, so only renamed identifiers
+// need to be set.
+type Schema struct {
+	SessionTable      string
+	SessionIDCol      string
+	TokenCol          string
+	UserIDCol         string // session column referencing the user
+	ExpiresCol        string
+	SessionCreatedCol string
+	SessionUpdatedCol string
+	IPAddressCol      string
+	UserAgentCol      string
+
+	UserTable        string
+	UserPKCol        string
+	EmailCol         string
+	NameCol          string
+	PlanCol          string
+	RoleCol          string
+	EmailVerifiedCol string
+	UserCreatedCol   string
+
+	APIKeyTable     string // apiKey plugin table, see WithAPIKeys
+	APIKeyUserIDCol string // API key column referencing the user
+
+	VerificationTable string // see ConsumeVerification
+
+	MemberTable     string // organization plugin membership table
+	MemberUserIDCol string // member column referencing the user
+	MemberOrgIDCol  string // member column referencing the organization
+}
+
+// DefaultSchema is Better Auth's default schema.
+var DefaultSchema = Schema{
+	SessionTable:      "session",
+	SessionIDCol:      "id",
+	TokenCol:          "token",
+	UserIDCol:         "userId",
+	ExpiresCol:        "expiresAt",
+	SessionCreatedCol: "createdAt",
+	SessionUpdatedCol: "updatedAt",
+	IPAddressCol:      "ipAddress",
+	UserAgentCol:      "userAgent",
+
+	UserTable:        "user",
+	UserPKCol:        "id",
+	EmailCol:         "email",
+	NameCol:          "name",
+	PlanCol:          "plan",
+	RoleCol:          "role",
+	EmailVerifiedCol: "emailVerified",
+	UserCreatedCol:   "createdAt",
+
+	APIKeyTable:     "apikey",
+	APIKeyUserIDCol: "userId",
+
+	VerificationTable: "verification",
+
+	MemberTable:     "member",
+	MemberUserIDCol: "userId",
+	MemberOrgIDCol:  "organizationId",
+}
+
+// WithSchema makes every query use the given table and column names.
+// An invalid schema makes all DB operations fail with the validation error.
+func WithSchema(s Schema) Option {
+	return func(v *Validator) {
+		v.schema = s
+	}
+}
+
+// fields returns pointers to every identifier in s, paired with those of d.
+func (s *Schema) fields(d *Schema) [][2]*string {
+	return [][2]*string{
+		{&s.SessionTable, &d.SessionTable},
+		{&s.SessionIDCol, &d.SessionIDCol},
+		{&s.TokenCol, &d.TokenCol},
+		{&s.UserIDCol, &d.UserIDCol},
+		{&s.ExpiresCol, &d.ExpiresCol},
+		{&s.SessionCreatedCol, &d.SessionCreatedCol},
+		{&s.SessionUpdatedCol, &d.SessionUpdatedCol},
+		{&s.IPAddressCol, &d.IPAddressCol},
+		{&s.UserAgentCol, &d.UserAgentCol},
+		{&s.UserTable, &d.UserTable},
+		{&s.UserPKCol, &d.UserPKCol},
+		{&s.EmailCol, &d.EmailCol},
+		{&s.NameCol, &d.NameCol},
+		{&s.PlanCol, &d.PlanCol},
+		{&s.RoleCol, &d.RoleCol},
+		{&s.EmailVerifiedCol, &d.EmailVerifiedCol},
+		{&s.UserCreatedCol, &d.UserCreatedCol},
+		{&s.APIKeyTable, &d.APIKeyTable},
+		{&s.APIKeyUserIDCol, &d.APIKeyUserIDCol},
+		{&s.VerificationTable, &d.VerificationTable},
+		{&s.MemberTable, &d.MemberTable},
+		{&s.MemberUserIDCol, &d.MemberUserIDCol},
+		{&s.MemberOrgIDCol, &d.MemberOrgIDCol},
+	}
+}
+
+// resolve fills empty fields from DefaultSchema, checks every identifier,
+// and returns a copy with each identifier quoted for use in SQL.
+func (s Schema) resolve() (Schema, error) {
+	d := DefaultSchema
+	for _, f := range s.fields(&d) {
+		if *f[0] == "" {
+			*f[0] = *f[1]
+		}
+		if err := checkIdent(*f[0]); err != nil {
+			return Schema{}, err
+		}
+		*f[0] = quoteIdent(*f[0])
+	}
+	if s.SessionTable == s.UserTable {
+		return Schema{}, errors.New("corral: schema session and user tables must differ")
+	}
+	return s, nil
+}
+
+// checkIdent rejects names that cannot be used as SQLite identifiers.
+func checkIdent(name string) error {
+	if name == "" || !utf8.ValidString(name) || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("corral: invalid schema identifier %q", name)
+	}
+	return nil
+}
+
+// quoteIdent quotes name as an SQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// queries holds the hot-path statements, built once from the schema.
+type queries struct {
+	selectSession []string // one per session lookup column, tried in order
+	selectUser    string
+	selectUsers   string // selectUser without the WHERE clause
+	selectUserBan string // selectUser plus banCols
+	userCols      string // the columns of selectUser, in scanUser order
+	banCols       string // the banned and banExpires columns
+}
+
+func buildQueries(sq Schema, lookupCols []string) queries {
+	selectSession := make([]string, len(lookupCols))
+	for i, col := range lookupCols {
+		selectSession[i] = fmt.Sprintf(`SELECT %s, %s, %s, %s, %s FROM %s WHERE %s = ?`,
+			sq.SessionIDCol, sq.TokenCol, sq.UserIDCol, sq.ExpiresCol, sq.SessionCreatedCol, sq.SessionTable, col)
+	}
+	userCols := fmt.Sprintf(`%s, %s, %s, %s, %s, %s, %s`,
+		sq.UserPKCol, sq.EmailCol, sq.NameCol, sq.PlanCol, sq.RoleCol, sq.EmailVerifiedCol, sq.UserCreatedCol)
+	selectUsers := fmt.Sprintf(`SELECT %s FROM %s`, userCols, sq.UserTable)
+	// Qualified so that SQLite reports missing columns instead of reading
+	// the names as string literals.
+	banCols := fmt.Sprintf(`%s."banned", %s."banExpires"`, sq.UserTable, sq.UserTable)
+	return queries{
+		selectSession: selectSession,
+		selectUser:    fmt.Sprintf(`%s WHERE %s = ?`, selectUsers, sq.UserPKCol),
+		selectUsers:   selectUsers,
+		selectUserBan: fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %s = ?`,
+			userCols, banCols, sq.UserTable, sq.UserPKCol),
+		userCols: userCols,
+		banCols:  banCols,
+	}
+}
@@ -0,0 +1,67 @@
+package corral
+
+import (
+	"crypto/subtle"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// WithServiceToken maps a static Bearer token to a synthetic user, letting
+// internal jobs call protected endpoints without a real session. Requests
+// presenting the token resolve to a copy of user without a DB lookup.
+func WithServiceToken(token string, user *User) Option {
+	return WithServiceTokens(map[string]*User{token: user})
+}
+
+// WithServiceTokens is like WithServiceToken for several tokens at once.
+func WithServiceTokens(tokens map[string]*User) Option {
+	return func(v *Validator) {
+		if v.serviceTokens == nil {
+			v.serviceTokens = make(map[string]*User, len(tokens))
+		}
+		for token, user := range tokens {
+			if token != "" && user != nil {
+				v.serviceTokens[token] = user
+			}
+		}
+	}
+}
+
+// serviceUser returns the synthetic user for a service token presented in
+// the Authorization header, or nil if r doesn't carry one.
+func (v *Validator) serviceUser(r *http.Request) *User {
+	if len(v.serviceTokens) == 0 {
+		return nil
+	}
+	bearer := bearerToken(r.Header.Get("Authorization"))
+	if bearer == "" || !wellFormedToken(bearer) {
+		return nil
+	}
+	if v.requireSecure && !v.isSecureRequest(r) {
+		return nil
+	}
+	return v.matchServiceToken(bearer)
+}
+
+// matchServiceToken returns a copy of the user for a configured service
+// token, or nil.
+func (v *Validator) matchServiceToken(bearer string) *User {
+	token := []byte(bearer)
+
+	// Compare against every configured token so timing doesn't reveal
+	// which (if any) matched.
+	var match *User
+	for candidate, user := range v.serviceTokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), token) == 1 {
+			match = user
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	u := *match
+	u.Extra = maps.Clone(match.Extra)
+	u.Roles = slices.Clone(match.Roles)
+	return &u
+}
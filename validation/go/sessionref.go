@@ -0,0 +1,48 @@
+package corral
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SessionRef carries a session across a process boundary, such as a job
+// queue, so it can be re-validated when the work runs. It contains the raw
+// token and must be protected like one.
+type SessionRef struct {
+	Token  string `json:"token"`
+	UserID string `json:"userId"`
+}
+
+// NewSessionRef captures token and the user it authenticated.
+func NewSessionRef(token string, u *User) SessionRef {
+	return SessionRef{Token: token, UserID: u.ID}
+}
+
+// Marshal encodes ref as JSON.
+func (ref SessionRef) Marshal() ([]byte, error) {
+	return json.Marshal(ref)
+}
+
+// Unmarshal decodes a ref produced by Marshal.
+func (ref *SessionRef) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, ref)
+}
+
+// ValidateRef re-checks ref at execution time. Unlike ValidateSession it
+// reports why the session is no longer usable: ErrSessionExpired if it
+// lapsed while queued, ErrSessionNotFound if it was revoked or now belongs
+// to a different user, or ErrUserBanned.
+func (v *Validator) ValidateRef(ctx context.Context, ref SessionRef) (*User, error) {
+	ctx, end := v.startTrace(ctx)
+	u, s, err := v.authenticate(ctx, ref.Token)
+	if err == nil && u.ID != ref.UserID {
+		u, s, err = nil, nil, ErrSessionNotFound
+	}
+	res := authResult{user: u, session: s, token: ref.Token, source: SourceArgument}
+	if isRejection(err) {
+		end(res, nil)
+	} else {
+		end(res, err)
+	}
+	return u, err
+}
@@ -0,0 +1,307 @@
+package corral
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SessionMeta is optional client information recorded with a new session.
+type SessionMeta struct {
+	IPAddress string
+	UserAgent string
+}
+
+// CreateSession inserts a session for userID that expires after ttl and
+// returns its token, for flows that authenticate the user outside Better
+// Auth (e.g. an SSO bridge). The caller sets the token as the session
+// cookie.
+func (v *Validator) CreateSession(ctx context.Context, userID string, ttl time.Duration, meta SessionMeta) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("corral: session ttl must be positive")
+	}
+	db, err := v.querier()
+	if err != nil {
+		return "", err
+	}
+	id, err := generateID()
+	if err != nil {
+		return "", err
+	}
+	token, err := generateID()
+	if err != nil {
+		return "", err
+	}
+	now := v.now()
+	sq := v.sq
+	query := fmt.Sprintf(`INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sq.SessionTable, sq.SessionIDCol, sq.TokenCol, sq.UserIDCol, sq.ExpiresCol,
+		sq.SessionCreatedCol, sq.SessionUpdatedCol, sq.IPAddressCol, sq.UserAgentCol)
+	_, err = db.ExecContext(ctx, query, id, v.dbToken(token), userID, formatTime(now.Add(ttl)),
+		formatTime(now), formatTime(now), nullString(meta.IPAddress), nullString(meta.UserAgent))
+	if err != nil {
+		return "", fmt.Errorf("corral: create session: %w", err)
+	}
+	if v.maxSessions > 0 {
+		if _, err := v.EnforceMaxSessions(ctx, userID, v.maxSessions); err != nil {
+			log.Printf("[corral] Failed to enforce session limit for %s: %v", userID, err)
+		}
+	}
+	return token, nil
+}
+
+// WithMaxSessions makes CreateSession call EnforceMaxSessions with n after
+// inserting the new session.
+func WithMaxSessions(n int) Option {
+	return func(v *Validator) {
+		v.maxSessions = n
+	}
+}
+
+// EnforceMaxSessions revokes userID's oldest unexpired sessions beyond the
+// newest max and returns how many were revoked. Counting and deleting
+// happen in one transaction.
+func (v *Validator) EnforceMaxSessions(ctx context.Context, userID string, max int) (int, error) {
+	if max < 0 {
+		return 0, errors.New("corral: negative session limit")
+	}
+	db, err := v.querier()
+	if err != nil {
+		return 0, err
+	}
+	tx, err := begin(ctx, db, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sq := v.sq
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s, %s, %s, %s FROM %s WHERE %s = ?`,
+		sq.SessionIDCol, sq.TokenCol, sq.ExpiresCol, sq.SessionCreatedCol, sq.SessionTable, sq.UserIDCol), userID)
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id, token string
+		created   time.Time
+	}
+	var active []row
+	now := v.now()
+	for rows.Next() {
+		var r row
+		var expiresAt, createdAt sql.NullString
+		if err := rows.Scan(&r.id, &r.token, &expiresAt, &createdAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		exp, err := parseTime(expiresAt.String)
+		if err != nil || !exp.After(now) {
+			continue
+		}
+		// Unparseable creation times sort as oldest.
+		r.created, _ = parseTime(createdAt.String)
+		active = append(active, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(active) <= max {
+		return 0, nil
+	}
+
+	slices.SortFunc(active, func(a, b row) int { return b.created.Compare(a.created) })
+	excess := active[max:]
+	del := fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, sq.SessionTable, sq.SessionIDCol)
+	for _, r := range excess {
+		if _, err := tx.ExecContext(ctx, del, r.id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, r := range excess {
+		v.invalidateKey(ctx, cacheKey(r.token))
+	}
+	return len(excess), nil
+}
+
+// idAlphabet and idLength match Better Auth's generateId.
+const (
+	idAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	idLength   = 32
+)
+
+// generateID returns a random identifier in Better Auth's format.
+func generateID() (string, error) {
+	b := make([]byte, idLength)
+	n := big.NewInt(int64(len(idAlphabet)))
+	for i := range b {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b[i] = idAlphabet[r.Int64()]
+	}
+	return string(b), nil
+}
+
+// nullString stores empty strings as NULL, as Better Auth does.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// CountActiveSessions returns the number of distinct users holding at least
+// one unexpired session, for seat-based billing.
+func (v *Validator) CountActiveSessions(ctx context.Context) (int, error) {
+	sq := v.sq
+	return v.countActiveUsers(ctx, fmt.Sprintf(`SELECT %s, %s FROM %s`,
+		sq.UserIDCol, sq.ExpiresCol, sq.SessionTable))
+}
+
+// CountActiveSessionsForOrg is like CountActiveSessions but only counts
+// members of the given organization (Better Auth organization plugin, see
+// Schema's MemberTable).
+func (v *Validator) CountActiveSessionsForOrg(ctx context.Context, orgID string) (int, error) {
+	sq := v.sq
+	return v.countActiveUsers(ctx, fmt.Sprintf(`SELECT s.%s, s.%s FROM %s s
+		 JOIN %s m ON m.%s = s.%s
+		 WHERE m.%s = ?`,
+		sq.UserIDCol, sq.ExpiresCol, sq.SessionTable,
+		sq.MemberTable, sq.MemberUserIDCol, sq.UserIDCol, sq.MemberOrgIDCol), orgID)
+}
+
+// countActiveUsers runs query, which must select userId and expiresAt, and
+// counts distinct users with an unexpired row. Expiry is compared in Go
+// because Better Auth's stored date formats don't sort reliably as text.
+func (v *Validator) countActiveUsers(ctx context.Context, query string, args ...any) (int, error) {
+	db, err := v.querier()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	now := v.now()
+	users := make(map[string]struct{})
+	for rows.Next() {
+		var userID string
+		var expiresAt sql.NullString
+		if err := rows.Scan(&userID, &expiresAt); err != nil {
+			return 0, err
+		}
+		exp, err := parseTime(expiresAt.String)
+		if err != nil || !exp.After(now) {
+			continue
+		}
+		users[userID] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return len(users), nil
+}
+
+// SessionFilter selects sessions for RevokeSessionsWhere. Zero fields are
+// ignored; set fields must all match.
+type SessionFilter struct {
+	UserID        string
+	OrgID         string // members of this organization (organization plugin)
+	IPAddress     string
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+}
+
+// RevokeSessionsWhere deletes every session matching f and returns how many
+// were removed. An empty filter is rejected rather than revoking everything.
+func (v *Validator) RevokeSessionsWhere(ctx context.Context, f SessionFilter) (int, error) {
+	if f == (SessionFilter{}) {
+		return 0, errors.New("corral: empty session filter")
+	}
+	db, err := v.querier()
+	if err != nil {
+		return 0, err
+	}
+
+	sq := v.sq
+	query := fmt.Sprintf(`SELECT s.%s, s.%s, s.%s FROM %s s`,
+		sq.SessionIDCol, sq.TokenCol, sq.SessionCreatedCol, sq.SessionTable)
+	var where []string
+	var args []any
+	if f.OrgID != "" {
+		query += fmt.Sprintf(` JOIN %s m ON m.%s = s.%s`, sq.MemberTable, sq.MemberUserIDCol, sq.UserIDCol)
+		where = append(where, fmt.Sprintf(`m.%s = ?`, sq.MemberOrgIDCol))
+		args = append(args, f.OrgID)
+	}
+	if f.UserID != "" {
+		where = append(where, fmt.Sprintf(`s.%s = ?`, sq.UserIDCol))
+		args = append(args, f.UserID)
+	}
+	if f.IPAddress != "" {
+		where = append(where, fmt.Sprintf(`s.%s = ?`, sq.IPAddressCol))
+		args = append(args, f.IPAddress)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	tx, err := begin(ctx, db, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Creation times are compared in Go, as in countActiveUsers.
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids, tokens []string
+	for rows.Next() {
+		var id, token string
+		var createdAt sql.NullString
+		if err := rows.Scan(&id, &token, &createdAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !f.CreatedBefore.IsZero() || !f.CreatedAfter.IsZero() {
+			created, err := parseTime(createdAt.String)
+			if err != nil ||
+				(!f.CreatedBefore.IsZero() && !created.Before(f.CreatedBefore)) ||
+				(!f.CreatedAfter.IsZero() && !created.After(f.CreatedAfter)) {
+				continue
+			}
+		}
+		ids = append(ids, id)
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	del := fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, sq.SessionTable, sq.SessionIDCol)
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, del, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, token := range tokens {
+		v.invalidateKey(ctx, cacheKey(token))
+	}
+	return len(ids), nil
+}
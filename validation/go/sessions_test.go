@@ -0,0 +1,60 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// newOrgTestValidator sets up u1 and u2 in org o1 and u3 in o2, each with
+// one live session, under a renamed membership table.
+func newOrgTestValidator(t *testing.T) (*Validator, *sql.DB) {
+	t.Helper()
+	v, db := newTestValidator(t, WithSchema(Schema{
+		MemberTable:     "org_member",
+		MemberUserIDCol: "memberId",
+		MemberOrgIDCol:  "orgId",
+	}))
+	if _, err := db.Exec(`CREATE TABLE org_member (id TEXT PRIMARY KEY, memberId TEXT, orgId TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, m := range [][2]string{{"u1", "o1"}, {"u2", "o1"}, {"u3", "o2"}} {
+		insertUser(t, db, m[0], "free", "user")
+		insertSession(t, db, "tok-"+m[0], m[0], now.Add(-time.Hour), now.Add(time.Hour))
+		if _, err := db.Exec(`INSERT INTO org_member VALUES (?, ?, ?)`, m[0]+m[1], m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return v, db
+}
+
+func TestCountActiveSessionsForOrgSchema(t *testing.T) {
+	v, _ := newOrgTestValidator(t)
+	n, err := v.CountActiveSessionsForOrg(context.Background(), "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("CountActiveSessionsForOrg(o1) = %d, want 2", n)
+	}
+}
+
+func TestRevokeSessionsWhereOrgSchema(t *testing.T) {
+	v, db := newOrgTestValidator(t)
+	n, err := v.RevokeSessionsWhere(context.Background(), SessionFilter{OrgID: "o1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("RevokeSessionsWhere(o1) = %d, want 2", n)
+	}
+	var left string
+	if err := db.QueryRow(`SELECT group_concat(userId) FROM session`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != "u3" {
+		t.Fatalf("sessions left for %q, want u3", left)
+	}
+}
@@ -0,0 +1,32 @@
+package corral
+
+import "time"
+
+// Query names passed to the WithSlowQueryThreshold callback.
+const (
+	QuerySession = "session"
+	QueryUser    = "user"
+)
+
+// WithSlowQueryThreshold calls cb with the query name (QuerySession or
+// QueryUser) and duration whenever a validation query takes at least d.
+// cb runs on the request path and should return quickly.
+func WithSlowQueryThreshold(d time.Duration, cb func(query string, d time.Duration)) Option {
+	return func(v *Validator) {
+		v.slowQueryAfter = d
+		v.onSlowQuery = cb
+	}
+}
+
+// timeQuery starts timing the named query; call the result once it is done.
+func (v *Validator) timeQuery(name string) func() {
+	if v.onSlowQuery == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		if d := time.Since(start); d >= v.slowQueryAfter {
+			v.onSlowQuery(name, d)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSnapshotLimit is the most sessions WithSnapshotRefresh holds in
+// memory; see WithSnapshotLimit.
+const DefaultSnapshotLimit = 100_000
+
+// WithSnapshotRefresh loads every unexpired session, with its user, into
+// memory every interval, so validating a token in the snapshot needs no
+// query. Tokens missing from it (e.g. sessions created since the last
+// load) fall back to the database as usual. The new snapshot replaces the
+// old one atomically once fully loaded.
+//
+// Snapshot hits are up to interval stale: bans, role and plan changes and
+// password-change invalidation apply from the next load, though
+// RevokeSession and friends take effect immediately on this instance (and
+// others sharing an InvalidationBus). Sessions that expire, or that are due
+// a WithSlidingExpiration refresh, are sent to the database.
+//
+// Each session costs roughly half a kilobyte. Loads that would exceed the
+// limit (DefaultSnapshotLimit, or WithSnapshotLimit) are abandoned with a
+// log line and validation uses the database until the count drops.
+func WithSnapshotRefresh(interval time.Duration) Option {
+	return func(v *Validator) {
+		if v.snap == nil {
+			v.snap = &snapshotter{limit: DefaultSnapshotLimit}
+		}
+		v.snap.interval = interval
+	}
+}
+
+// WithSnapshotLimit caps the number of sessions WithSnapshotRefresh keeps.
+func WithSnapshotLimit(n int) Option {
+	return func(v *Validator) {
+		if v.snap == nil {
+			v.snap = &snapshotter{}
+		}
+		v.snap.limit = n
+	}
+}
+
+type snapshotEntry struct {
+	user    *User // shared by the user's sessions; copied on every hit
+	session Session
+}
+
+type snapshotter struct {
+	interval time.Duration
+	limit    int
+
+	current atomic.Pointer[map[string]snapshotEntry] // keyed by cacheKey
+	// dropped records keys invalidated since the current snapshot was
+	// loaded, with the time of invalidation.
+	dropped sync.Map
+}
+
+// get returns copies of the snapshot's user and session for key.
+func (sn *snapshotter) get(key string) (*User, *Session, bool) {
+	entries := sn.current.Load()
+	if entries == nil {
+		return nil, nil, false
+	}
+	e, ok := (*entries)[key]
+	if !ok {
+		return nil, nil, false
+	}
+	if _, gone := sn.dropped.Load(key); gone {
+		return nil, nil, false
+	}
+	u, s := *e.user, e.session
+	u.Extra = maps.Clone(u.Extra)
+	u.Roles = slices.Clone(u.Roles)
+	return &u, &s, true
+}
+
+func (sn *snapshotter) drop(key string, now time.Time) {
+	sn.dropped.Store(key, now)
+}
+
+// snapshotHit serves token from the snapshot unless it has expired or is
+// due a sliding refresh as of now.
+func (v *Validator) snapshotHit(key string, now time.Time) (*User, *Session, bool) {
+	u, s, ok := v.snap.get(key)
+	if !ok || !s.ExpiresAt.After(now) || v.refreshDue(s, now) {
+		return nil, nil, false
+	}
+	return u, s, true
+}
+
+// startSnapshots loads the first snapshot right away, then one every
+// interval until shutdown.
+func (v *Validator) startSnapshots() {
+	v.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(v.snap.interval)
+		defer ticker.Stop()
+		for {
+			if err := v.loadSnapshot(context.Background()); err != nil {
+				log.Printf("[corral] Session snapshot not refreshed: %v", err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (v *Validator) loadSnapshot(ctx context.Context) error {
+	db, err := v.querier()
+	if err != nil {
+		return err
+	}
+	started := v.now()
+	sq := v.sq
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT %s, %s, %s, %s, %s FROM %s`,
+		sq.SessionIDCol, sq.TokenCol, sq.UserIDCol, sq.ExpiresCol, sq.SessionCreatedCol, sq.SessionTable))
+	if err != nil {
+		return err
+	}
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		var userID, expiresAt, createdAt sql.NullString
+		if err := rows.Scan(&s.ID, &s.Token, &userID, &expiresAt, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if !userID.Valid || !expiresAt.Valid {
+			continue
+		}
+		if s.ExpiresAt, err = parseTime(expiresAt.String); err != nil || !s.ExpiresAt.After(started) {
+			continue
+		}
+		if createdAt.Valid {
+			if s.CreatedAt, err = parseTime(createdAt.String); err != nil {
+				continue
+			}
+		}
+		s.UserID = userID.String
+		s.LongLived = s.ExpiresAt.Sub(s.CreatedAt) > v.longLivedAfter
+		sessions = append(sessions, s)
+		if len(sessions) > v.snap.limit {
+			rows.Close()
+			v.snap.current.Store(nil)
+			return fmt.Errorf("more than %d live sessions", v.snap.limit)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Users are loaded in batches together with their ban and
+	// credential-change columns; banned users and credential-invalidated
+	// sessions are left out so the database path reports them.
+	userDB, err := v.usersQueryer(db)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, s := range sessions {
+		if !seen[s.UserID] {
+			seen[s.UserID] = true
+			userIDs = append(userIDs, s.UserID)
+		}
+	}
+	checks := make(map[string]userChecks)
+	users, err := v.usersByIDs(ctx, userDB, userIDs, checks)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]snapshotEntry, len(sessions))
+	for _, s := range sessions {
+		u := users[s.UserID]
+		if u == nil || checks[u.ID].ban.activeAt(u.ID, started) {
+			continue
+		}
+		stale, err := createdBefore(&s, checks[u.ID].changedAt)
+		if err != nil {
+			return err
+		}
+		if !stale {
+			entries[cacheKey(s.Token)] = snapshotEntry{user: u, session: s}
+		}
+	}
+
+	v.snap.current.Store(&entries)
+	v.snap.dropped.Range(func(key, at any) bool {
+		if at.(time.Time).Before(started) {
+			v.snap.dropped.Delete(key)
+		}
+		return true
+	})
+	return nil
+}
@@ -0,0 +1,56 @@
+package corral
+
+import (
+	"errors"
+	"maps"
+)
+
+// RejectionReason names a class of rejection for WithStatusCodes.
+type RejectionReason string
+
+const (
+	ReasonNoToken          RejectionReason = "no_token"          // 401
+	ReasonExpired          RejectionReason = "expired"           // 401
+	ReasonNotFound         RejectionReason = "not_found"         // 401
+	ReasonDBError          RejectionReason = "db_error"          // 500; the session couldn't be checked
+	ReasonInsufficientPlan RejectionReason = "insufficient_plan" // 403
+	ReasonInsufficientRole RejectionReason = "insufficient_role" // 403
+	ReasonBanned           RejectionReason = "banned"            // 403
+)
+
+// WithStatusCodes overrides the HTTP status the middlewares answer with for
+// the given reasons, e.g. {ReasonExpired: 419}. Unlisted reasons keep the
+// defaults noted on each constant. WithUnauthorizedRedirect only applies to
+// reasons still answered with 401.
+func WithStatusCodes(codes map[RejectionReason]int) Option {
+	return func(v *Validator) {
+		v.statusCodes = maps.Clone(codes)
+	}
+}
+
+// statusFor returns the configured status for reason, or def.
+func (v *Validator) statusFor(reason RejectionReason, def int) int {
+	if code, ok := v.statusCodes[reason]; ok {
+		return code
+	}
+	return def
+}
+
+// rejectionReason classifies err for statusFor; "" matches no reason.
+func rejectionReason(err error) RejectionReason {
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return ReasonNoToken
+	case errors.Is(err, ErrSessionExpired):
+		return ReasonExpired
+	case errors.Is(err, ErrSessionNotFound):
+		return ReasonNotFound
+	case errors.Is(err, ErrInsufficientPlan):
+		return ReasonInsufficientPlan
+	case errors.Is(err, ErrInsufficientRole):
+		return ReasonInsufficientRole
+	case errors.Is(err, ErrUserBanned):
+		return ReasonBanned
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package corral
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestDBErrorStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes map[RejectionReason]int
+		want  int
+	}{
+		{"default", nil, http.StatusInternalServerError},
+		{"overridden", map[RejectionReason]int{ReasonDBError: http.StatusServiceUnavailable}, http.StatusServiceUnavailable},
+		{"unauthorized", map[RejectionReason]int{ReasonDBError: http.StatusUnauthorized}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "auth.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+			v, err := newValidator("", []Option{WithQuerier(db), WithStatusCodes(tt.codes)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer v.Close()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer tok1")
+			rec := httptest.NewRecorder()
+			v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package corral
+
+import "regexp"
+
+// DefaultTokenPattern matches the tokens Better Auth generates, letters
+// and digits, and also allows the base64 and base64url alphabets (+ / _ -
+// and = padding) so that tokens from a custom generateId keep working.
+var DefaultTokenPattern = regexp.MustCompile(`^[A-Za-z0-9+/_=-]+$`)
+
+// WithTokenPattern sets the format a session token must have to be looked
+// up; anything else is rejected as not found without a query. The default
+// is DefaultTokenPattern. Pass nil to look up every token, e.g. when a
+// custom generateId uses characters outside base64.
+func WithTokenPattern(pattern *regexp.Regexp) Option {
+	return func(v *Validator) {
+		v.tokenPattern = pattern
+	}
+}
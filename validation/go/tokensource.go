@@ -0,0 +1,41 @@
+package corral
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithPreferredTokenSource sets where extraction looks for the session
+// token first: SourceCookie (the default) or SourceBearer. The other source
+// is only consulted when the preferred one carries no token, so set
+// SourceBearer for API-only services to skip cookie parsing. A request
+// carrying both uses only the preferred one.
+func WithPreferredTokenSource(source string) Option {
+	return func(v *Validator) {
+		v.preferredSource = source
+	}
+}
+
+// checkPreferredSource validates the WithPreferredTokenSource value.
+func (v *Validator) checkPreferredSource() error {
+	switch v.preferredSource {
+	case "", SourceCookie, SourceBearer:
+		return nil
+	}
+	return fmt.Errorf("corral: preferred token source must be %q or %q, not %q",
+		SourceCookie, SourceBearer, v.preferredSource)
+}
+
+// mayHaveSessionCookie reports whether r's Cookie headers mention a session
+// cookie name, so requests without one skip parsing every cookie.
+func (v *Validator) mayHaveSessionCookie(r *http.Request) bool {
+	for _, h := range r.Header.Values("Cookie") {
+		for _, name := range v.cookieNames {
+			if strings.Contains(h, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package corral
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func BenchmarkMiddleware(b *testing.B) {
+	now := time.Now()
+	withCookie := func(r *http.Request) {
+		r.Header.Set("Cookie", "theme=dark; _ga=GA1.2.3; "+CookieName+"=tok1; csrf=abc")
+	}
+	withBearer := func(r *http.Request) {
+		r.Header.Set("Cookie", "theme=dark; _ga=GA1.2.3; csrf=abc")
+		r.Header.Set("Authorization", "Bearer tok1")
+	}
+	benchmarks := []struct {
+		name    string
+		opts    []Option
+		prepare func(*http.Request)
+	}{
+		{"cookie", nil, withCookie},
+		{"cookie/preferred", []Option{WithPreferredTokenSource(SourceCookie)}, withCookie},
+		{"bearer", nil, withBearer},
+		{"bearer/preferred", []Option{WithPreferredTokenSource(SourceBearer)}, withBearer},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			opts := append([]Option{WithSessionCache(time.Minute, 16)}, bm.opts...)
+			v, db := newTestValidator(b, opts...)
+			insertUser(b, db, "u1", "free", "user")
+			insertSession(b, db, "tok1", "u1", now, now.Add(time.Hour))
+			h := v.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			bm.prepare(r)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				b.Fatalf("status %d, want 200", w.Code)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package corral
+
+import "context"
+
+// Token sources reported in ValidationEvent.Source.
+const (
+	SourceCookie      = "cookie"
+	SourceBearer      = "bearer"
+	SourceCookieCache = "cookie_cache"
+	SourceService     = "service"
+	SourceJWT         = "jwt"
+	SourceAPIKey      = "api_key"
+	SourceArgument    = "argument" // passed directly to ValidateSessionContext
+)
+
+// Validation results reported in ValidationEvent.Result.
+const (
+	ResultValid   = "valid"
+	ResultInvalid = "invalid"
+	ResultError   = "error"
+)
+
+// ValidationEvent describes the outcome of one validation.
+type ValidationEvent struct {
+	Result    string
+	Source    string // empty if the request carried no token
+	UserID    string
+	RequestID string // set by the middlewares with WithRequestIDHeader
+	Err       error
+}
+
+// Tracer is notified around each validation, typically to record a span.
+// Start returns the context to validate with and a function that is called
+// exactly once with the outcome. The corralotel subpackage provides an
+// OpenTelemetry implementation.
+type Tracer interface {
+	Start(ctx context.Context) (context.Context, func(ValidationEvent))
+}
+
+// WithTracer traces ValidateSessionContext, ValidateRequest and the
+// middlewares with t.
+func WithTracer(t Tracer) Option {
+	return func(v *Validator) {
+		v.tracer = t
+	}
+}
+
+// startTrace begins tracing a validation. The returned function must be
+// called with its outcome.
+func (v *Validator) startTrace(ctx context.Context) (context.Context, func(authResult, error)) {
+	if v.tracer == nil {
+		return ctx, func(authResult, error) {}
+	}
+	ctx, end := v.tracer.Start(ctx)
+	return ctx, func(res authResult, err error) {
+		e := ValidationEvent{Result: ResultInvalid, Source: res.source, RequestID: res.requestID, Err: err}
+		switch {
+		case err != nil:
+			e.Result = ResultError
+		case res.user != nil:
+			e.Result = ResultValid
+			e.UserID = res.user.ID
+		}
+		end(e)
+	}
+}
@@ -0,0 +1,21 @@
+package corral
+
+// WithTokenTransform re-encodes each incoming token into the form stored in
+// the session table just before it is used in a query, e.g. hex-decoding
+// and base64-encoding it after a storage migration. Caches, rate limits and
+// RevokeSession still take the token as the client sends it; CreateSession
+// and RotateSession store fn of the new token and return it untransformed.
+// fn must accept the tokens they generate (alphanumeric) if either is used.
+func WithTokenTransform(fn func(cookieToken string) (dbToken string)) Option {
+	return func(v *Validator) {
+		v.tokenTransform = fn
+	}
+}
+
+// dbToken returns token as stored in the session table.
+func (v *Validator) dbToken(token string) string {
+	if v.tokenTransform == nil {
+		return token
+	}
+	return v.tokenTransform(token)
+}
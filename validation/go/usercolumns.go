@@ -0,0 +1,126 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// WithUserColumns loads additional user columns, such as Better Auth
+// additionalFields, into User.Extra keyed by column name. NULL values are
+// omitted. Columns missing from the database are logged once and skipped.
+func WithUserColumns(cols ...string) Option {
+	return func(v *Validator) {
+		v.userCols.requested = cols
+	}
+}
+
+// LocaleFromContext returns the "locale" column of the authenticated user,
+// loaded with WithUserColumns("locale").
+func LocaleFromContext(ctx context.Context) string {
+	return extraFromContext(ctx, "locale")
+}
+
+// TimezoneFromContext returns the "timezone" column of the authenticated
+// user, loaded with WithUserColumns("timezone").
+func TimezoneFromContext(ctx context.Context) string {
+	return extraFromContext(ctx, "timezone")
+}
+
+func extraFromContext(ctx context.Context, col string) string {
+	if u := UserFromContext(ctx); u != nil {
+		return u.Extra[col]
+	}
+	return ""
+}
+
+// userColumns tracks which requested extra columns exist.
+type userColumns struct {
+	requested []string
+
+	mu       sync.Mutex
+	resolved bool
+	query    string // empty if none of the columns exist
+	names    []string
+}
+
+// loadUserColumns fills u.Extra from the requested columns.
+func (v *Validator) loadUserColumns(ctx context.Context, db Querier, u *User) error {
+	if len(v.userCols.requested) == 0 {
+		return nil
+	}
+	query, names, err := v.userColumnsQuery(ctx, db)
+	if err != nil || query == "" {
+		return err
+	}
+	vals := make([]sql.NullString, len(names))
+	dest := make([]any, len(names))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := db.QueryRowContext(ctx, query, u.ID).Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	u.Extra = make(map[string]string, len(names))
+	for i, name := range names {
+		if vals[i].Valid {
+			u.Extra[name] = vals[i].String
+		}
+	}
+	return nil
+}
+
+// userColumnsQuery builds the extra-columns query from the columns that
+// exist, checking the schema on first use.
+func (v *Validator) userColumnsQuery(ctx context.Context, db Querier) (string, []string, error) {
+	c := &v.userCols
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resolved {
+		return c.query, c.names, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, unquoteIdent(v.sq.UserTable))
+	if err != nil {
+		return "", nil, err
+	}
+	have := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return "", nil, err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if len(have) == 0 {
+		// Table not created yet; try again next time.
+		return "", nil, nil
+	}
+
+	var quoted []string
+	for _, col := range c.requested {
+		if !have[col] {
+			log.Printf("[corral] User column %q not found; skipping", col)
+			continue
+		}
+		c.names = append(c.names, col)
+		quoted = append(quoted, quoteIdent(col))
+	}
+	if len(quoted) > 0 {
+		c.query = fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`,
+			strings.Join(quoted, ", "), v.sq.UserTable, v.sq.UserPKCol)
+	}
+	c.resolved = true
+	return c.query, c.names, nil
+}
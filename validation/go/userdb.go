@@ -0,0 +1,60 @@
+package corral
+
+import (
+	"database/sql"
+)
+
+// WithUserDBPath reads users from a separate SQLite file (or DSN for the
+// configured driver) at path, for deployments where another service owns
+// the user table. Sessions, API keys and everything else stay in the main
+// database. The driver, WithSQLiteKey and pool settings apply to both
+// pools; Close closes both.
+// With WithConsistentRead, only the session lookup runs in the read
+// transaction, as the two databases can't share one.
+func WithUserDBPath(path string) Option {
+	return func(v *Validator) {
+		v.userDBPath = path
+		v.userDB = nil
+	}
+}
+
+// WithUserDB is like WithUserDBPath but uses an already-open pool, which
+// the caller keeps ownership of: Close leaves it open.
+func WithUserDB(db *sql.DB) Option {
+	return func(v *Validator) {
+		v.userDB = db
+		v.userDBPath = ""
+	}
+}
+
+// openUsers returns the pool holding the user table: the WithUserDB or
+// WithUserDBPath one, opened on first use, or the main database.
+func (v *Validator) openUsers() (Querier, error) {
+	if v.userDB == nil && v.userDBPath == "" {
+		return v.querier()
+	}
+	if v.configErr != nil {
+		return nil, v.configErr
+	}
+	v.dbMu.Lock()
+	defer v.dbMu.Unlock()
+	if v.userDB == nil {
+		db, err := sql.Open(v.driverName, v.dsnFor(v.userDBPath))
+		if err != nil {
+			return nil, err
+		}
+		v.pool.apply(db)
+		v.userDB = db
+		v.ownUserDB = true
+	}
+	return v.userDB, nil
+}
+
+// usersQueryer returns q, which reads the main database, or the separate
+// user database if one is configured.
+func (v *Validator) usersQueryer(q Querier) (Querier, error) {
+	if v.userDB == nil && v.userDBPath == "" {
+		return q, nil
+	}
+	return v.openUsers()
+}
@@ -0,0 +1,47 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UserExistenceCheck selects when validation confirms the session's user
+// row still exists; see WithUserExistenceCheck.
+type UserExistenceCheck int
+
+const (
+	// CheckUserOnCacheMiss trusts cached results: a user deleted while
+	// their session is cached stays valid until the entry expires (at most
+	// the WithSessionCache TTL). This is the default.
+	CheckUserOnCacheMiss UserExistenceCheck = iota
+	// CheckUserAlways confirms the user row exists on every validation,
+	// including cache hits. Deletions take effect immediately, at the
+	// cost of one indexed primary-key read per cached request.
+	CheckUserAlways
+)
+
+// WithUserExistenceCheck sets when validation checks that the user row
+// still exists. Choose CheckUserAlways for tenants where a deleted account
+// must lose access at once, and keep the default where cache speed matters
+// more. Without WithSessionCache the two behave the same.
+func WithUserExistenceCheck(mode UserExistenceCheck) Option {
+	return func(v *Validator) {
+		v.userCheck = mode
+	}
+}
+
+// userExists reports whether the user table has a row for id.
+func (v *Validator) userExists(ctx context.Context, id string) (bool, error) {
+	db, err := v.openUsers()
+	if err != nil {
+		return false, err
+	}
+	var one int
+	err = db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = ?`, v.sq.UserTable, v.sq.UserPKCol), id).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,175 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// List limits for ListUsers.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListOptions pages through ListUsers.
+type ListOptions struct {
+	Limit       int // DefaultListLimit if zero; capped at MaxListLimit
+	Offset      int
+	SearchEmail string // case-insensitive substring match on email
+}
+
+// ListUsers returns users newest first, for admin panels. Users created at
+// the same time are ordered by ID so pages are stable.
+func (v *Validator) ListUsers(ctx context.Context, opts ListOptions) ([]User, error) {
+	db, err := v.openUsers()
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+
+	sq := v.sq
+	query := v.queries.selectUsers
+	var args []any
+	if opts.SearchEmail != "" {
+		query += fmt.Sprintf(` WHERE %s LIKE ? ESCAPE '\'`, sq.EmailCol)
+		args = append(args, "%"+escapeLike(opts.SearchEmail)+"%")
+	}
+	query += fmt.Sprintf(` ORDER BY %s DESC, %s DESC LIMIT ? OFFSET ?`, sq.UserCreatedCol, sq.UserPKCol)
+	args = append(args, limit, max(opts.Offset, 0))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var scanned []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		scanned = append(scanned, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Completed once the rows are closed, as in usersByIDs.
+	var users []User
+	for _, u := range scanned {
+		if u, err = v.finishUser(ctx, db, u, nil); err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, nil
+}
+
+// escapeLike escapes LIKE wildcards in s, using backslash as the escape.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// maxQueryVars bounds the parameters bound to one IN (...) query, well
+// under SQLite's SQLITE_MAX_VARIABLE_NUMBER on every supported version.
+const maxQueryVars = 500
+
+// GetUsersByIDs fetches the users with the given IDs in batches, keyed by
+// ID. IDs without a user row are absent from the result.
+func (v *Validator) GetUsersByIDs(ctx context.Context, ids []string) (map[string]*User, error) {
+	db, err := v.openUsers()
+	if err != nil {
+		return nil, err
+	}
+	return v.usersByIDs(ctx, db, ids, nil)
+}
+
+// userChecks holds the columns usersByIDs reads alongside a user for the
+// ban and credential-change checks.
+type userChecks struct {
+	ban       banState
+	changedAt sql.NullString // the WithInvalidateOnPasswordChange column
+}
+
+// usersByIDs is GetUsersByIDs against db. A non-nil checks receives each
+// user's ban and credential-change columns, read in the same queries;
+// columns found missing are dropped and their checks disabled, as in
+// getUserWithBan and predatesCredentialChange.
+func (v *Validator) usersByIDs(ctx context.Context, db Querier, ids []string, checks map[string]userChecks) (map[string]*User, error) {
+	withBan := checks != nil && !v.banColsMissing.Load()
+	withChanged := checks != nil && v.pwChangedCol != "" && !v.pwColMissing.Load()
+	cols := v.queries.userCols
+	if withBan {
+		cols += ", " + v.queries.banCols
+	}
+	if withChanged {
+		cols += fmt.Sprintf(", %s.%s", v.sq.UserTable, quoteIdent(v.pwChangedCol))
+	}
+	users := make(map[string]*User, len(ids))
+	for start := 0; start < len(ids); start += maxQueryVars {
+		chunk := ids[start:min(start+maxQueryVars, len(ids))]
+		query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s IN (%s)`,
+			cols, v.sq.UserTable, v.sq.UserPKCol, placeholders(len(chunk)))
+		rows, err := db.QueryContext(ctx, query, anySlice(chunk)...)
+		switch {
+		case err == nil:
+		case withBan && (isMissingTableColumn(err, "banned") || isMissingTableColumn(err, "banExpires")):
+			v.disableBanChecks()
+			return v.usersByIDs(ctx, db, ids, checks)
+		case withChanged && isMissingTableColumn(err, v.pwChangedCol):
+			v.disablePasswordCheck()
+			return v.usersByIDs(ctx, db, ids, checks)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var c userChecks
+			var extra []any
+			if withBan {
+				extra = append(extra, &c.ban.banned, &c.ban.expires)
+			}
+			if withChanged {
+				extra = append(extra, &c.changedAt)
+			}
+			u, err := scanUser(rows, extra...)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			users[u.ID] = u
+			if checks != nil {
+				checks[u.ID] = c
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range users {
+		if _, err := v.finishUser(ctx, db, u, nil); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// placeholders returns n comma-separated "?".
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func anySlice(s []string) []any {
+	args := make([]any, len(s))
+	for i, v := range s {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,45 @@
+package corral
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListUsersMatchesGetUsersByIDs(t *testing.T) {
+	v, db := newTestValidator(t,
+		WithUserColumns("locale"),
+		WithUserPostProcessor(func(u *User) { u.Name = "processed " + u.Name }))
+	if _, err := db.Exec(`ALTER TABLE user ADD COLUMN locale TEXT`); err != nil {
+		t.Fatal(err)
+	}
+	insertUser(t, db, "u1", "Pro", "admin,user")
+	insertUser(t, db, "u2", "free", "user")
+	if _, err := db.Exec(`UPDATE user SET locale = 'de-DE' WHERE id = 'u1'`); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	listed, err := v.ListUsers(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID, err := v.GetUsersByIDs(ctx, []string{"u1", "u2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(byID) {
+		t.Fatalf("ListUsers returned %d users, want %d", len(listed), len(byID))
+	}
+	for _, u := range listed {
+		want := byID[u.ID]
+		if want == nil {
+			t.Fatalf("ListUsers returned unknown user %q", u.ID)
+		}
+		if u.Name != want.Name || u.Plan != want.Plan || u.Extra["locale"] != want.Extra["locale"] {
+			t.Errorf("ListUsers %q = %+v, GetUsersByIDs = %+v", u.ID, u, *want)
+		}
+	}
+	if byID["u1"].Name != "processed u1" || byID["u1"].Extra["locale"] != "de-DE" {
+		t.Errorf("GetUsersByIDs u1 = %+v, want post-processed with locale", *byID["u1"])
+	}
+}
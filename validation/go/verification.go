@@ -0,0 +1,47 @@
+package corral
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrVerificationNotFound means no verification row has the given value,
+	// including when it has already been consumed.
+	ErrVerificationNotFound = errors.New("corral: verification not found")
+	// ErrVerificationExpired means the verification existed but had expired.
+	// The row is deleted all the same.
+	ErrVerificationExpired = errors.New("corral: verification expired")
+)
+
+// ConsumeVerification redeems a one-time token from Better Auth's
+// verification table (Schema's VerificationTable), such as a magic-link
+// token, and returns its identifier (usually the email). The row is looked
+// up and deleted in a single statement, so each value can be consumed only
+// once; a value consumed before, by this or a concurrent call, is gone and
+// cannot be told apart from one that never existed, so both return
+// ErrVerificationNotFound.
+func (v *Validator) ConsumeVerification(ctx context.Context, value string) (identifier string, err error) {
+	db, err := v.querier()
+	if err != nil {
+		return "", err
+	}
+	var expiresAt sql.NullString
+	query := fmt.Sprintf(`DELETE FROM %s WHERE "value" = ? RETURNING "identifier", "expiresAt"`,
+		v.sq.VerificationTable)
+	err = db.QueryRowContext(ctx, query, value).Scan(&identifier, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", ErrVerificationNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	exp, err := parseTime(expiresAt.String)
+	if err != nil || !exp.After(v.now()) {
+		return "", ErrVerificationExpired
+	}
+	return identifier, nil
+}
@@ -0,0 +1,52 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConsumeVerification(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	v, db := newTestValidator(t,
+		WithClock(func() time.Time { return now }),
+		WithSchema(Schema{VerificationTable: "magic_link"}))
+	if _, err := db.Exec(`CREATE TABLE magic_link (id TEXT PRIMARY KEY, identifier TEXT, value TEXT,
+		expiresAt TEXT, createdAt TEXT, updatedAt TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []struct {
+		value   string
+		expires time.Time
+	}{
+		{"live", now.Add(time.Minute)},
+		{"stale", now.Add(-time.Minute)},
+	} {
+		_, err := db.Exec(`INSERT INTO magic_link (id, identifier, value, expiresAt) VALUES (?, ?, ?, ?)`,
+			"v-"+row.value, row.value+"@example.com", row.value, formatTime(row.expires))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   error
+	}{
+		{"valid", "live", "live@example.com", nil},
+		{"single use", "live", "", ErrVerificationNotFound},
+		{"expired", "stale", "", ErrVerificationExpired},
+		{"expired row deleted", "stale", "", ErrVerificationNotFound},
+		{"not found", "missing", "", ErrVerificationNotFound},
+	}
+	for _, tt := range tests {
+		got, err := v.ConsumeVerification(ctx, tt.value)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: ConsumeVerification(%q) = %q, %v; want %q, %v", tt.name, tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
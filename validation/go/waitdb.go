@@ -0,0 +1,63 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// waitForDBInterval is how often Warmup polls while waiting for the DB.
+const waitForDBInterval = 250 * time.Millisecond
+
+// WithWaitForDB makes Warmup wait up to timeout for the database file to
+// exist and pass VerifySchema, for when the auth server creates it after
+// this process starts.
+func WithWaitForDB(timeout time.Duration) Option {
+	return func(v *Validator) {
+		v.waitForDB = timeout
+	}
+}
+
+// awaitDB polls until the database is usable or the WithWaitForDB timeout
+// (or ctx) expires, returning the last error seen.
+func (v *Validator) awaitDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, v.waitForDB)
+	defer cancel()
+	ticker := time.NewTicker(waitForDBInterval)
+	defer ticker.Stop()
+	for {
+		err := v.dbReady(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("corral: database not ready after %v: %w", v.waitForDB, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (v *Validator) dbReady(ctx context.Context) error {
+	if path := dbFilePath(v.dbPath); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	return v.VerifySchema(ctx, false)
+}
+
+// dbFilePath extracts the file name from a SQLite DSN, or "" for in-memory
+// databases.
+func dbFilePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path
+}